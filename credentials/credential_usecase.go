@@ -0,0 +1,459 @@
+package credentials
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/susatyo441/go-ta-utils/db"
+	"github.com/susatyo441/go-ta-utils/entity"
+	"github.com/susatyo441/go-ta-utils/mail"
+	"github.com/susatyo441/go-ta-utils/model"
+	"github.com/susatyo441/go-ta-utils/service"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrResetEmailNotSent wraps the error of a password reset email that could not be sent. Only returned
+// for emails with an account, so answer it like success (e.g. the same 202) and log it, or it reveals accounts.
+var ErrResetEmailNotSent = errors.New("password reset email not sent")
+
+type ICredentialUseCase interface {
+	// Check the password of the user with this email. The stored hash is upgraded
+	// when it uses another algorithm or weaker parameters than the Hasher.
+	Login(
+		ctx context.Context,
+		email string,
+		password string,
+	) (*model.User, *entity.HttpError)
+	// Validate password against the policy, then hash and save it.
+	SetPassword(
+		ctx context.Context,
+		user model.User,
+		password string,
+	) *entity.HttpError
+	// Create an activation token and email the activation link to the user.
+	SendActivationEmail(
+		ctx context.Context,
+		user model.User,
+		activationLink string,
+	) error
+	// Consume an activation token and set the first password of its user.
+	Activate(
+		ctx context.Context,
+		token string,
+		password string,
+	) (*model.User, *entity.HttpError)
+	// Create a reset token and email the reset link when a user has this email.
+	// Returns nil for unknown emails, and ErrResetEmailNotSent when the email cannot be sent.
+	//
+	// EXAMPLE:
+	//
+	//	err := useCase.RequestPasswordReset(ctx, body.Email, "https://app.example.com/reset-password")
+	//	if errors.Is(err, credentials.ErrResetEmailNotSent) {
+	//		logger.FromContext(ctx).Error("Failed to send password reset email", "error", err)
+	//	} else if err != nil {
+	//		return err
+	//	}
+	//	return ctx.SendStatus(fiber.StatusAccepted)
+	RequestPasswordReset(
+		ctx context.Context,
+		email string,
+		resetLink string,
+	) error
+	// Consume a reset token and replace the password of its user.
+	ResetPassword(
+		ctx context.Context,
+		token string,
+		password string,
+	) (*model.User, *entity.HttpError)
+	// Create the indexes of the credential tokens collection, expired tokens are deleted by Mongo.
+	EnsureCredentialIndexes(ctx context.Context) error
+}
+
+type CredentialUseCaseOptions struct {
+	// Defaults to NewHasher().
+	Hasher *Hasher
+	// Defaults to DefaultPasswordPolicy.
+	Policy *PasswordPolicy
+	// Defaults to 24 hours, as stated by mail.TemplateUserActivation.
+	ActivationTTL time.Duration
+	// Defaults to 1 hour, as stated by mail.TemplatePasswordReset.
+	ResetTTL time.Duration
+	// Defaults to mail.Default().
+	Mailer mail.Mailer
+	// Renders mail.TemplateUserActivation and mail.TemplatePasswordReset with mail.LinkData.
+	// Defaults to mail.NewDefaultTemplates().
+	Templates *mail.Templates
+}
+
+type CredentialUseCase struct {
+	UserService            service.Service[model.User]
+	CredentialTokenService service.Service[model.CredentialToken]
+	Options                CredentialUseCaseOptions
+}
+
+func determineCredentialOptions(opts ...CredentialUseCaseOptions) CredentialUseCaseOptions {
+	actualOpts := CredentialUseCaseOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.Hasher == nil {
+		actualOpts.Hasher = NewHasher()
+	}
+	if actualOpts.Policy == nil {
+		actualOpts.Policy = &DefaultPasswordPolicy
+	}
+	if actualOpts.ActivationTTL == 0 {
+		actualOpts.ActivationTTL = 24 * time.Hour
+	}
+	if actualOpts.ResetTTL == 0 {
+		actualOpts.ResetTTL = time.Hour
+	}
+	if actualOpts.Mailer == nil {
+		actualOpts.Mailer = mail.Default()
+	}
+	if actualOpts.Templates == nil {
+		actualOpts.Templates = mail.NewDefaultTemplates()
+	}
+
+	return actualOpts
+}
+
+func NewCompanyCredentialUseCase(companyCode string, opts ...CredentialUseCaseOptions) ICredentialUseCase {
+	return &CredentialUseCase{
+		UserService:            service.NewCompanyService[model.User](companyCode, db.UserModelName),
+		CredentialTokenService: service.NewCompanyService[model.CredentialToken](companyCode, db.CredentialTokenModelName),
+		Options:                determineCredentialOptions(opts...),
+	}
+}
+
+func NewAdminCredentialUseCase(opts ...CredentialUseCaseOptions) ICredentialUseCase {
+	return &CredentialUseCase{
+		UserService:            service.NewAdminService[model.User](db.UserModelName),
+		CredentialTokenService: service.NewAdminService[model.CredentialToken](db.CredentialTokenModelName),
+		Options:                determineCredentialOptions(opts...),
+	}
+}
+
+func (u *CredentialUseCase) Login(
+	ctx context.Context,
+	email string,
+	password string,
+) (*model.User, *entity.HttpError) {
+	user, err := u.UserService.FindOne(ctx, bson.M{"email": email})
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, entity.InternalServerError(err.Error())
+	}
+
+	if user == nil || user.Password == nil {
+		// Hash anyway, so unknown emails take as long as wrong passwords.
+		_, _ = u.Options.Hasher.Hash(password)
+		return nil, entity.Unauthorized("Invalid email or password")
+	}
+
+	match, needsRehash, verifyErr := u.Options.Hasher.Verify(password, *user.Password)
+	if verifyErr != nil {
+		return nil, entity.InternalServerError(verifyErr.Error())
+	}
+	if !match {
+		return nil, entity.Unauthorized("Invalid email or password")
+	}
+
+	if needsRehash {
+		// Best effort, the login succeeds even if the upgrade fails.
+		if hash, err := u.Options.Hasher.Hash(password); err == nil {
+			_, _ = u.UserService.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"password": hash}})
+			user.Password = &hash
+		}
+	}
+
+	return user, nil
+}
+
+func (u *CredentialUseCase) SetPassword(
+	ctx context.Context,
+	user model.User,
+	password string,
+) *entity.HttpError {
+	if err := u.validatePassword(user, password); err != nil {
+		return err
+	}
+
+	return u.savePassword(ctx, user.ID, password)
+}
+
+func (u *CredentialUseCase) SendActivationEmail(
+	ctx context.Context,
+	user model.User,
+	activationLink string,
+) error {
+	token, err := u.issueToken(ctx, user.ID, model.CredentialTokenActivation, u.Options.ActivationTTL)
+	if err != nil {
+		return err
+	}
+
+	return u.sendLink(ctx, mail.TemplateUserActivation, user, linkWithToken(activationLink, token))
+}
+
+func (u *CredentialUseCase) Activate(
+	ctx context.Context,
+	token string,
+	password string,
+) (*model.User, *entity.HttpError) {
+	return u.consumeAndSetPassword(ctx, token, model.CredentialTokenActivation, password)
+}
+
+func (u *CredentialUseCase) RequestPasswordReset(
+	ctx context.Context,
+	email string,
+	resetLink string,
+) error {
+	user, err := u.UserService.FindOne(ctx, bson.M{"email": email})
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := u.issueToken(ctx, user.ID, model.CredentialTokenPasswordReset, u.Options.ResetTTL)
+	if err != nil {
+		return err
+	}
+
+	if err := u.sendLink(ctx, mail.TemplatePasswordReset, *user, linkWithToken(resetLink, token)); err != nil {
+		return fmt.Errorf("%w: %w", ErrResetEmailNotSent, err)
+	}
+
+	return nil
+}
+
+func (u *CredentialUseCase) ResetPassword(
+	ctx context.Context,
+	token string,
+	password string,
+) (*model.User, *entity.HttpError) {
+	return u.consumeAndSetPassword(ctx, token, model.CredentialTokenPasswordReset, password)
+}
+
+func (u *CredentialUseCase) EnsureCredentialIndexes(ctx context.Context) error {
+	if err := u.CredentialTokenService.SetDeleteFromDatabaseAttribute(ctx, bson.M{"expiresAt": 1}); err != nil {
+		return err
+	}
+
+	return u.CredentialTokenService.MakeUnique(ctx, bson.M{"tokenHash": 1})
+}
+
+// issueToken creates a token, invalidating the unused tokens of the same purpose.
+func (u *CredentialUseCase) issueToken(
+	ctx context.Context,
+	userId primitive.ObjectID,
+	purpose string,
+	ttl time.Duration,
+) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", errors.New("failed to generate token")
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	now := time.Now()
+	if _, err := u.CredentialTokenService.UpdateMany(
+		ctx,
+		bson.M{"userId": userId, "purpose": purpose, "usedAt": nil},
+		bson.M{"$set": bson.M{"usedAt": now}},
+	); err != nil {
+		return "", err
+	}
+
+	if _, err := u.CredentialTokenService.InsertOne(ctx, model.CredentialToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userId,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(ttl),
+	}); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (u *CredentialUseCase) consumeAndSetPassword(
+	ctx context.Context,
+	token string,
+	purpose string,
+	password string,
+) (*model.User, *entity.HttpError) {
+	now := time.Now()
+	filter := bson.M{
+		"tokenHash": hashToken(token),
+		"purpose":   purpose,
+		"usedAt":    nil,
+		"expiresAt": bson.M{"$gt": now},
+	}
+
+	credentialToken, err := u.CredentialTokenService.FindOne(ctx, filter)
+	if err == mongo.ErrNoDocuments {
+		return nil, entity.BadRequest("Invalid or expired token")
+	}
+	if err != nil {
+		return nil, entity.InternalServerError(err.Error())
+	}
+
+	user, httpErr := u.UserService.GetOneOrFail(ctx, bson.M{"_id": credentialToken.UserID})
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	// Validated before consuming, so a rejected password does not burn the token.
+	if httpErr := u.validatePassword(*user, password); httpErr != nil {
+		return nil, httpErr
+	}
+
+	// Consume atomically, a token can only be used once even on concurrent requests.
+	count, err := u.CredentialTokenService.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"usedAt": now}})
+	if err != nil {
+		return nil, entity.InternalServerError(err.Error())
+	}
+	if count == 0 {
+		return nil, entity.BadRequest("Invalid or expired token")
+	}
+
+	if httpErr := u.savePassword(ctx, user.ID, password); httpErr != nil {
+		return nil, httpErr
+	}
+
+	return user, nil
+}
+
+func (u *CredentialUseCase) validatePassword(user model.User, password string) *entity.HttpError {
+	userInputs := []string{user.Name, user.Email}
+	if user.PhoneNumber != nil {
+		userInputs = append(userInputs, *user.PhoneNumber)
+	}
+
+	return u.Options.Policy.Validate(password, userInputs...)
+}
+
+func (u *CredentialUseCase) savePassword(
+	ctx context.Context,
+	userId primitive.ObjectID,
+	password string,
+) *entity.HttpError {
+	hash, err := u.Options.Hasher.Hash(password)
+	if err != nil {
+		return entity.InternalServerError(err.Error())
+	}
+
+	if _, err := u.UserService.UpdateOne(ctx, bson.M{"_id": userId}, bson.M{"$set": bson.M{"password": hash}}); err != nil {
+		return entity.InternalServerError(err.Error())
+	}
+
+	return nil
+}
+
+// sendLink emails the link to the user with the template name.
+func (u *CredentialUseCase) sendLink(ctx context.Context, name string, user model.User, link string) error {
+	msg, err := u.Options.Templates.Render(name, mail.LinkData{Name: user.Name, Link: link})
+	if err != nil {
+		return err
+	}
+	msg.To = []string{user.Email}
+
+	return u.Options.Mailer.Send(ctx, msg)
+}
+
+// linkWithToken replaces "{token}" in link, or appends the token as the "token" query parameter.
+func linkWithToken(link string, token string) string {
+	if strings.Contains(link, "{token}") {
+		return strings.ReplaceAll(link, "{token}", url.QueryEscape(token))
+	}
+
+	separator := "?"
+	if strings.Contains(link, "?") {
+		separator = "&"
+	}
+
+	return link + separator + "token=" + url.QueryEscape(token)
+}
+
+// Tokens are random, a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type MockCredentialUseCase struct {
+	mock.Mock
+}
+
+func (m *MockCredentialUseCase) Login(
+	ctx context.Context,
+	email string,
+	password string,
+) (*model.User, *entity.HttpError) {
+	args := m.Called(ctx, email, password)
+	return args.Get(0).(*model.User), args.Get(1).(*entity.HttpError)
+}
+
+func (m *MockCredentialUseCase) SetPassword(
+	ctx context.Context,
+	user model.User,
+	password string,
+) *entity.HttpError {
+	args := m.Called(ctx, user, password)
+	return args.Get(0).(*entity.HttpError)
+}
+
+func (m *MockCredentialUseCase) SendActivationEmail(
+	ctx context.Context,
+	user model.User,
+	activationLink string,
+) error {
+	args := m.Called(ctx, user, activationLink)
+	return args.Error(0)
+}
+
+func (m *MockCredentialUseCase) Activate(
+	ctx context.Context,
+	token string,
+	password string,
+) (*model.User, *entity.HttpError) {
+	args := m.Called(ctx, token, password)
+	return args.Get(0).(*model.User), args.Get(1).(*entity.HttpError)
+}
+
+func (m *MockCredentialUseCase) RequestPasswordReset(
+	ctx context.Context,
+	email string,
+	resetLink string,
+) error {
+	args := m.Called(ctx, email, resetLink)
+	return args.Error(0)
+}
+
+func (m *MockCredentialUseCase) ResetPassword(
+	ctx context.Context,
+	token string,
+	password string,
+) (*model.User, *entity.HttpError) {
+	args := m.Called(ctx, token, password)
+	return args.Get(0).(*model.User), args.Get(1).(*entity.HttpError)
+}
+
+func (m *MockCredentialUseCase) EnsureCredentialIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
@@ -0,0 +1,183 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var ErrInvalidHash = errors.New("invalid password hash")
+
+type Argon2Params struct {
+	// Memory in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendation (19 MiB, 2 iterations, 1 thread).
+var DefaultArgon2Params = Argon2Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+type HasherOptions struct {
+	// AlgorithmArgon2id or AlgorithmBcrypt, used for new hashes. Defaults to AlgorithmArgon2id.
+	Algorithm string
+	// Defaults to DefaultArgon2Params.
+	Argon2 Argon2Params
+	// Defaults to bcrypt.DefaultCost.
+	BcryptCost int
+}
+
+// Hasher hashes passwords and tells when a stored hash should be upgraded.
+// Verifies both argon2id (PHC string format) and bcrypt hashes, whatever the configured algorithm.
+type Hasher struct {
+	options HasherOptions
+}
+
+// NewHasher is a constructor to initialize Hasher
+func NewHasher(opts ...HasherOptions) *Hasher {
+	actualOpts := HasherOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.Algorithm == "" {
+		actualOpts.Algorithm = AlgorithmArgon2id
+	}
+	if actualOpts.Argon2 == (Argon2Params{}) {
+		actualOpts.Argon2 = DefaultArgon2Params
+	}
+	if actualOpts.BcryptCost == 0 {
+		actualOpts.BcryptCost = bcrypt.DefaultCost
+	}
+
+	return &Hasher{options: actualOpts}
+}
+
+// Hash hashes password with the configured algorithm.
+//
+// EXAMPLE:
+//
+//	hash, err := credentials.NewHasher().Hash("s3cret-Passw0rd")
+//	// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.options.Algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.options.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+
+	params := h.options.Argon2
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", errors.New("failed to generate salt")
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		params.Memory,
+		params.Iterations,
+		params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify reports whether password matches hash, and whether hash uses another algorithm
+// or weaker parameters than configured, in which case it should be replaced by Hash(password)
+// after a successful login.
+func (h *Hasher) Verify(password string, hash string) (match bool, needsRehash bool, err error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := decodeArgon2Hash(hash)
+		if err != nil {
+			return false, false, err
+		}
+
+		computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false, nil
+		}
+
+		wanted := h.options.Argon2
+		needsRehash = h.options.Algorithm != AlgorithmArgon2id ||
+			params.Memory < wanted.Memory ||
+			params.Iterations < wanted.Iterations ||
+			params.Parallelism < wanted.Parallelism ||
+			uint32(len(salt)) < wanted.SaltLength ||
+			uint32(len(key)) < wanted.KeyLength
+
+		return true, needsRehash, nil
+	}
+
+	if strings.HasPrefix(hash, "$2") {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, ErrInvalidHash
+		}
+
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false, false, ErrInvalidHash
+		}
+
+		needsRehash = h.options.Algorithm != AlgorithmBcrypt || cost < h.options.BcryptCost
+		return true, needsRehash, nil
+	}
+
+	return false, false, ErrInvalidHash
+}
+
+func decodeArgon2Hash(hash string) (Argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	params := Argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,106 @@
+package credentials
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/susatyo441/go-ta-utils/entity"
+)
+
+type PasswordPolicy struct {
+	MinLength int
+	// bcrypt ignores bytes after the 72nd, keep MaxLength <= 72 when using it.
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Passwords that are rejected whatever the rules, compared case-insensitively.
+	Blocklist []string
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:    8,
+	MaxLength:    72,
+	RequireUpper: true,
+	RequireLower: true,
+	RequireDigit: true,
+	Blocklist:    []string{"password", "password1", "12345678", "123456789", "qwerty123", "Passw0rd"},
+}
+
+// Validate checks password against the policy. userInputs (name, email, phone number...)
+// must not be contained in the password.
+// Returns entity.InvalidFields listing every broken rule on the "password" field.
+//
+// EXAMPLE:
+//
+//	if err := credentials.DefaultPasswordPolicy.Validate(body.Password, user.Name, user.Email); err != nil {
+//		return err.SendResponse(ctx)
+//	}
+func (p PasswordPolicy) Validate(password string, userInputs ...string) *entity.HttpError {
+	messages := []string{}
+	length := utf8.RuneCountInString(password)
+
+	if p.MinLength > 0 && length < p.MinLength {
+		messages = append(messages, "must be at least "+strconv.Itoa(p.MinLength)+" characters")
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		messages = append(messages, "must be at most "+strconv.Itoa(p.MaxLength)+" bytes")
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		messages = append(messages, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		messages = append(messages, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		messages = append(messages, "must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		messages = append(messages, "must contain a symbol")
+	}
+
+	lower := strings.ToLower(password)
+	for _, blocked := range p.Blocklist {
+		if lower == strings.ToLower(blocked) {
+			messages = append(messages, "is too common")
+			break
+		}
+	}
+	for _, input := range userInputs {
+		// Also check the local part of emails.
+		input, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(input)), "@")
+		if len(input) >= 3 && strings.Contains(lower, input) {
+			messages = append(messages, "must not contain personal information")
+			break
+		}
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	fields := make([]entity.FieldError, 0, len(messages))
+	for _, message := range messages {
+		fields = append(fields, entity.FieldError{Field: "password", Message: message})
+	}
+
+	return entity.InvalidFields("Password does not meet the requirements", fields)
+}
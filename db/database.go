@@ -3,10 +3,10 @@ package db
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"sync"
 
+	"github.com/susatyo441/go-ta-utils/logger"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -32,15 +32,17 @@ func ConnectMongo() *mongo.Client {
 		var err error
 		clientInstance, err = mongo.Connect(context.TODO(), clientOptions)
 		if err != nil {
-			log.Fatal("Failed to connect to MongoDB:", err)
+			logger.Default().Error("Failed to connect to MongoDB", "error", err)
+			os.Exit(1)
 		}
 
 		err = clientInstance.Ping(context.TODO(), nil)
 		if err != nil {
-			log.Fatal("Failed to ping MongoDB:", err)
+			logger.Default().Error("Failed to ping MongoDB", "error", err)
+			os.Exit(1)
 		}
 
-		fmt.Println("MongoDB client initialized!")
+		logger.Default().Info("MongoDB client initialized")
 	})
 
 	return clientInstance
@@ -53,12 +55,25 @@ func ConnectToCustomDb(db string) {
 
 func ConnectToCompanyDb(companyCode string) {
 	Client = ConnectMongo()
-	dbName = fmt.Sprintf("%s_tagsamurai", companyCode)
+	dbName = CompanyDbName(companyCode)
 }
 
 func ConnectToPartnerDb(partnerId string) {
 	Client = ConnectMongo()
-	dbName = fmt.Sprintf("%s_admin_tagsamurai", partnerId)
+	dbName = PartnerDbName(partnerId)
+}
+
+// AdminDbName is the name of the admin database.
+const AdminDbName = "admin_tagsamurai"
+
+// CompanyDbName returns the name of the database of a company.
+func CompanyDbName(companyCode string) string {
+	return fmt.Sprintf("%s_tagsamurai", companyCode)
+}
+
+// PartnerDbName returns the name of the database of a partner.
+func PartnerDbName(partnerId string) string {
+	return fmt.Sprintf("%s_admin_tagsamurai", partnerId)
 }
 
 func ConnectToGlobalDb() {
@@ -68,7 +83,7 @@ func ConnectToGlobalDb() {
 
 func ConnectToAdminDb() {
 	Client = ConnectMongo()
-	dbName = "admin_tagsamurai"
+	dbName = AdminDbName
 }
 
 func ConnectToShopVisionDb() {
@@ -1,13 +1,21 @@
 package db
 
 const (
-	CategoryModelName     = "categories"
-	ProductModelName      = "products"
-	StoreModelName        = "stores"
-	TransactionsModelName = "transactions"
-	UserModelName         = "users"
-	ProductPhotoModelName = "product_photos"
-	ChangelogModelName    = "change_logs"
-	QuestionerModelName   = "questioners"
-	CreditModelName       = "credits"
+	CategoryModelName        = "categories"
+	ProductModelName         = "products"
+	StoreModelName           = "stores"
+	TransactionsModelName    = "transactions"
+	UserModelName            = "users"
+	ProductPhotoModelName    = "product_photos"
+	ChangelogModelName       = "change_logs"
+	QuestionerModelName      = "questioners"
+	CreditModelName          = "credits"
+	SessionModelName         = "sessions"
+	APIKeyModelName          = "api_keys"
+	TenantModelName          = "tenants"
+	CredentialTokenModelName = "credential_tokens"
+	RateLimitModelName       = "rate_limits"
+	IdempotencyModelName     = "idempotency_keys"
+	FileReferenceModelName   = "file_references"
+	ImageJobModelName        = "image_jobs"
 )
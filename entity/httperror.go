@@ -6,7 +6,15 @@ import (
 )
 
 type HttpError struct {
-	Code    int    `json:"code"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Value   string `json:"value"`
 	Message string `json:"message"`
 }
 
@@ -15,7 +23,7 @@ func (e *HttpError) Error() string {
 }
 
 func (e *HttpError) SendResponse(ctx *fiber.Ctx) error {
-	return response.SendResponse(ctx, e.Code, nil, e.Message)
+	return response.SendResponse(ctx, e.Code, e.Data, e.Message)
 }
 
 func InternalServerError(message string) *HttpError {
@@ -32,6 +40,15 @@ func BadRequest(message string) *HttpError {
 	}
 }
 
+// InvalidFields is a BadRequest carrying the list of rejected fields as response data.
+func InvalidFields(message string, fields []FieldError) *HttpError {
+	return &HttpError{
+		Code:    fiber.StatusBadRequest,
+		Message: message,
+		Data:    fields,
+	}
+}
+
 func Unauthorized(message string) *HttpError {
 	return &HttpError{
 		Code:    fiber.StatusUnauthorized,
@@ -52,3 +69,24 @@ func NotFound(message string) *HttpError {
 		Message: message,
 	}
 }
+
+func TooManyRequests(message string) *HttpError {
+	return &HttpError{
+		Code:    fiber.StatusTooManyRequests,
+		Message: message,
+	}
+}
+
+func Conflict(message string) *HttpError {
+	return &HttpError{
+		Code:    fiber.StatusConflict,
+		Message: message,
+	}
+}
+
+func UnprocessableEntity(message string) *HttpError {
+	return &HttpError{
+		Code:    fiber.StatusUnprocessableEntity,
+		Message: message,
+	}
+}
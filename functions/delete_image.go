@@ -1,26 +1,35 @@
 package functions
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"path"
+	"strings"
+
+	"github.com/susatyo441/go-ta-utils/storage"
 )
 
-// DeleteImage menghapus file gambar berdasarkan folder dan nama file
+// DeleteImage melepas referensi file gambar berdasarkan folder dan nama file dari storage.Default(),
+// file hanya dihapus jika sudah tidak direferensikan lagi (lihat storage.SetReferenceCounter).
+// Folder dan nama file yang mengandung ".." ditolak, sehingga file di luar root storage tidak bisa dihapus.
 func DeleteImage(folderName, fileName string) error {
-	// Dapatkan path absolut folder tempat gambar disimpan
-	absPath, err := filepath.Abs(filepath.Join("../acts-files", folderName, fileName))
-	if err != nil {
-		return fmt.Errorf("error mendapatkan path file: %w", err)
+	// Nama file harus satu segmen, folder tidak boleh keluar dari root
+	if fileName == "" || fileName == "." || strings.ContainsAny(fileName, "/\\") {
+		return fmt.Errorf("nama file tidak valid: %s", fileName)
 	}
-
-	// Periksa apakah file ada sebelum dihapus
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		return fmt.Errorf("file tidak ditemukan: %s", absPath)
+	if _, err := storage.CleanKey(folderName + "/" + fileName); err != nil {
+		return fmt.Errorf("path file tidak valid: %s", path.Join(folderName, fileName))
 	}
 
-	// Hapus file
-	if err := os.Remove(absPath); err != nil {
+	key := path.Join(folderName, fileName)
+
+	// Hapus file, error jika file tidak ada
+	_, err := storage.Release(context.Background(), storage.Default(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("file tidak ditemukan: %s", key)
+	}
+	if err != nil {
 		return fmt.Errorf("gagal menghapus file: %w", err)
 	}
 
@@ -1,10 +1,9 @@
 package functions
 
 import (
-	"fmt"
-
 	"github.com/gofiber/fiber/v2"
 	"github.com/susatyo441/go-ta-utils/entity"
+	"github.com/susatyo441/go-ta-utils/logger"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -28,14 +27,14 @@ func ParamToObjectID(
 
 	castedParam, castErr := primitive.ObjectIDFromHex(param)
 	if castErr == primitive.ErrInvalidHex {
-		fmt.Println(castErr)
+		logger.FromContext(ctx.UserContext()).Debug("Invalid ObjectId param", "param", paramName, "error", castErr)
 		if len(errMsg) == 0 {
 			return castedParam, entity.BadRequest(paramName + " is not a valid ObjectId")
 		} else {
 			return castedParam, entity.BadRequest(errMsg[0])
 		}
 	} else if castErr != nil {
-		fmt.Println(castErr)
+		logger.FromContext(ctx.UserContext()).Warn("Error converting param to ObjectId", "param", paramName, "error", castErr)
 		return castedParam, entity.InternalServerError("Error converting " + paramName + " to ObjectId")
 	}
 
@@ -1,15 +1,14 @@
 package functions
 
 import (
-	"fmt"
-	"log"
-	"os"
-	"strconv"
+	"context"
+	"sync"
 
-	"gopkg.in/gomail.v2"
+	"github.com/susatyo441/go-ta-utils/logger"
+	"github.com/susatyo441/go-ta-utils/mail"
 )
 
-// SendEmail send an email.
+// SendEmail send an email with mail.Default().
 // Parameters:
 //   - content: The HTML content of the email. Typically, this would be generated using the GenerateUserActivationContent function.
 //   - subject: The subject of the email. A string describing the email's purpose.
@@ -18,68 +17,47 @@ import (
 //   - bcc: A slice of strings containing the email addresses of any BCC recipients. Can be nil or an empty slice if there are no BCC recipients.
 //
 // Returns:
-//   - void (The function logs success or failure to the console.).
+//   - void (The function logs success or failure with logger.Default()).
 //
-// Usage:
-//   - This function is used to send emails. It is often used in conjunction with the GenerateUserActivationContent function to send user activation emails. See the example for a demonstration.
-//   - Example:
-//     content := GenerateUserActivationContent("user123", "https://example.com/activate/token123").
-//     SendEmail(content, "Activate Your Account", "user@example.com", nil, nil).
+// Deprecated: Use mail.Default().Send, which returns why the email could not be sent, with mail.Templates.
 func SendEmail(content, subject, to string, cc, bcc []string) {
-	// Load environment variables for mail server configuration
-	mailerUser := os.Getenv("MAILER_USER")
-	mailerPassword := os.Getenv("MAILER_PASSWORD")
-	mailerHost := os.Getenv("MAILER_HOST")
-	mailerPort, parseErr := strconv.Atoi(os.Getenv("MAILER_PORT"))
-	if parseErr != nil {
-		log.Fatalf("Invalid MAILER_PORT value: %v", parseErr)
-	}
-
-	// Set up the mail message
-	message := gomail.NewMessage()
-	message.SetHeader("From", mailerUser)
-	message.SetHeader("To", to)
-	if len(cc) > 0 {
-		message.SetHeader("Cc", cc...)
-	}
-	if len(bcc) > 0 {
-		message.SetHeader("Bcc", bcc...)
+	err := mail.Default().Send(context.Background(), mail.Message{
+		To:      []string{to},
+		Cc:      cc,
+		Bcc:     bcc,
+		Subject: subject,
+		HTML:    content,
+	})
+	if err != nil {
+		logger.Default().Error("Failed to send email", "error", err, "subject", subject)
+	} else {
+		logger.Default().Info("Email sent successfully", "subject", subject)
 	}
-	message.SetHeader("Subject", subject)
-	message.SetBody("text/html", content)
+}
 
-	// Set up the SMTP transport
-	dialer := gomail.NewDialer(mailerHost, mailerPort, mailerUser, mailerPassword)
+var defaultTemplates = sync.OnceValue(mail.NewDefaultTemplates)
 
-	// Send the email
-	if err := dialer.DialAndSend(message); err != nil {
-		log.Printf("Failed to send email: %v", err)
-	} else {
-		log.Println("Email sent successfully")
+// renderDefaultHTML renders the HTML of a template of mail.NewDefaultTemplates.
+func renderDefaultHTML(name string, data mail.LinkData) string {
+	msg, err := defaultTemplates().Render(name, data)
+	if err != nil {
+		// The default templates only use the fields of LinkData.
+		panic(err)
 	}
+
+	return msg.HTML
 }
 
 // GenerateUserActivationContent generates the HTML email content
+//
+// Deprecated: Render mail.TemplateUserActivation of mail.NewDefaultTemplates, which also has a plain-text alternative.
 func GenerateUserActivationContent(username, activationLink string) string {
-	return fmt.Sprintf(`
-	<!DOCTYPE html>
-	<html lang="en">
-	<head>
-		<style>
-			@import url("https://fonts.googleapis.com/css?family=Roboto");
-			body { font-family: "Roboto"; padding: 2rem; }
-			.button { padding: 8px 16px; background-color: #0063f7; color: white; border: none; border-radius: 8px; text-decoration: none; }
-		</style>
-	</head>
-	<body>
-		<div><img src="https://assets.tagsamurai.com/img/logo-ipsum.png" width="168" /></div>
-		<p>Hello %s,</p>
-		<p>Please click the button below to activate your account.</p>
-		<a href="%s" class="button">Activate Account</a>
-		<p>If the button does not working properly, please copy the link below and paste to your browser:</p>
-		<a href="%s">%s</a>
-		<p>This link will be expired in 24 hours since you received this email.</p>
-		<p><small>This is an autogenerated email. Please do not reply this email.</small></p>
-	</body>
-	</html>`, username, activationLink, activationLink, activationLink)
+	return renderDefaultHTML(mail.TemplateUserActivation, mail.LinkData{Name: username, Link: activationLink})
+}
+
+// GeneratePasswordResetContent generates the HTML email content of a password reset
+//
+// Deprecated: Render mail.TemplatePasswordReset of mail.NewDefaultTemplates, which also has a plain-text alternative.
+func GeneratePasswordResetContent(username, resetLink string) string {
+	return renderDefaultHTML(mail.TemplatePasswordReset, mail.LinkData{Name: username, Link: resetLink})
 }
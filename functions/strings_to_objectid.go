@@ -1,8 +1,7 @@
 package functions
 
 import (
-	"fmt"
-
+	"github.com/susatyo441/go-ta-utils/logger"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -14,7 +13,7 @@ func StringsToObjectId(ids []string) ([]primitive.ObjectID, int) {
 		oid, castErr := primitive.ObjectIDFromHex(id)
 
 		if castErr != nil {
-			fmt.Println("Error converting", id, "to ObjectId:", castErr)
+			logger.Default().Debug("Error converting to ObjectId", "id", id, "error", castErr)
 			errors += 1
 		} else {
 			oids = append(oids, oid)
@@ -1,16 +1,14 @@
 package functions
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"image"
 	"mime/multipart"
-	"os"
-	"path/filepath"
-	"strconv"
-	"time"
+	"path"
 
 	"github.com/disintegration/imaging"
+	"github.com/susatyo441/go-ta-utils/storage"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -24,6 +22,10 @@ const (
 	High   PhotoSizeKey = "high"
 )
 
+// SaveMultiImages saves the images of attributes in storage.Default(), under "<storeId>/<folderName>/",
+// as PNG when transparent and JPEG otherwise,
+// named by their content hash so identical photos share one file (see storage.SaveImage).
+// Returns the path of each saved attribute, relative to the store ("/<folderName>/<sha256>.<jpg|png>").
 func SaveMultiImages(storeId primitive.ObjectID, folderName string, attributes []string, files map[string]*multipart.FileHeader, size PhotoSizeKey) (map[string]string, error) {
 	// Tentukan faktor pengurangan berdasarkan ukuran yang dipilih
 	var scaleFactor float64
@@ -46,15 +48,9 @@ func SaveMultiImages(storeId primitive.ObjectID, folderName string, attributes [
 			continue // Skip kalau file-nya gak dikirim
 		}
 
-		file, err := fileHeader.Open()
+		img, mimeType, err := storage.DecodeUpload(fileHeader)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open file: %w", err)
-		}
-		defer file.Close()
-
-		img, _, err := image.Decode(file)
-		if err != nil {
-			return nil, fmt.Errorf("error decoding image: %w", err)
+			return nil, err
 		}
 
 		if scaleFactor < 1.0 {
@@ -63,20 +59,13 @@ func SaveMultiImages(storeId primitive.ObjectID, folderName string, attributes [
 			img = imaging.Resize(img, newWidth, newHeight, imaging.Lanczos)
 		}
 
-		absPath, _ := filepath.Abs(fmt.Sprintf("../acts-files/%s/%s", storeId.Hex(), folderName))
-		timestamp := strconv.FormatInt(time.Now().UnixNano(), 10)
-		outputFilePath := filepath.Join(absPath, fmt.Sprintf("%s.jpg", timestamp))
-		relativePath := fmt.Sprintf("/%s/%s.jpg", folderName, timestamp)
-
-		if err := os.MkdirAll(absPath, os.ModePerm); err != nil {
-			return nil, fmt.Errorf("error creating directory: %w", err)
-		}
-
-		if err := imaging.Save(img, outputFilePath); err != nil {
+		dir := path.Join(storeId.Hex(), folderName)
+		key, err := storage.SaveImage(context.Background(), storage.Default(), dir, img, storage.SaveImageOptions{SourceType: mimeType})
+		if err != nil {
 			return nil, fmt.Errorf("error saving resized image: %w", err)
 		}
 
-		result[attribute] = relativePath
+		result[attribute] = "/" + path.Join(folderName, path.Base(key))
 	}
 
 	if len(result) == 0 {
@@ -85,3 +74,41 @@ func SaveMultiImages(storeId primitive.ObjectID, folderName string, attributes [
 
 	return result, nil
 }
+
+// SaveImageVariants saves each variant of the image of fileHeader in storage.Default(), under "<storeId>/<folderName>/".
+// Returns the path of each variant by name, relative to the store ("/<folderName>/<sha256>.<jpg|png>").
+//
+// EXAMPLE:
+//
+//	paths, err := functions.SaveImageVariants(storeId, "logo", fileHeader, storage.DefaultVariants)
+//	store.LogoSmall = functions.MakePointer(paths["small"])
+func SaveImageVariants(
+	storeId primitive.ObjectID,
+	folderName string,
+	fileHeader *multipart.FileHeader,
+	variants []storage.Variant,
+	opts ...storage.SaveImageOptions,
+) (map[string]string, error) {
+	img, mimeType, err := storage.DecodeUpload(fileHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	saveOpts := storage.SaveImageOptions{}
+	if len(opts) > 0 {
+		saveOpts = opts[0]
+	}
+	saveOpts.SourceType = mimeType
+
+	keys, err := storage.SaveVariants(context.Background(), storage.Default(), path.Join(storeId.Hex(), folderName), img, variants, saveOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(keys))
+	for name, key := range keys {
+		result[name] = "/" + path.Join(folderName, path.Base(key))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+type contextKey string
+
+const (
+	loggerKey    = contextKey("logger")
+	requestIDKey = contextKey("requestId")
+)
+
+var base atomic.Pointer[slog.Logger]
+
+// Default returns the base logger of the library, slog.Default() unless SetDefault was called.
+// Prefer FromContext when a context is available.
+func Default() *slog.Logger {
+	if l := base.Load(); l != nil {
+		return l
+	}
+	return slog.Default()
+}
+
+// SetDefault replaces the base logger of the library.
+func SetDefault(l *slog.Logger) {
+	base.Store(l)
+}
+
+type Options struct {
+	// "json" or "text". Defaults to the LOG_FORMAT env var, then "json".
+	Format string
+	// Defaults to the LOG_LEVEL env var (debug, info, warn, error), then info.
+	Level slog.Leveler
+	// Defaults to os.Stdout.
+	Output io.Writer
+}
+
+// New creates a logger from options and env vars.
+//
+// EXAMPLE:
+//
+//	logger.SetDefault(logger.New())
+func New(opts ...Options) *slog.Logger {
+	actualOpts := Options{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.Format == "" {
+		actualOpts.Format = os.Getenv("LOG_FORMAT")
+	}
+	if actualOpts.Level == nil {
+		level := slog.LevelInfo
+		_ = level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL")))
+		actualOpts.Level = level
+	}
+	if actualOpts.Output == nil {
+		actualOpts.Output = os.Stdout
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: actualOpts.Level}
+	if strings.EqualFold(actualOpts.Format, "text") {
+		return slog.New(slog.NewTextHandler(actualOpts.Output, handlerOpts))
+	}
+
+	return slog.New(slog.NewJSONHandler(actualOpts.Output, handlerOpts))
+}
+
+// WithContext returns a copy of ctx carrying l.
+func WithContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the logger of ctx (with the request fields set by middleware.RequestLogger),
+// or Default().
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerKey).(*slog.Logger); ok && l != nil {
+			return l
+		}
+	}
+	return Default()
+}
+
+// With adds attributes to the logger of ctx.
+func With(ctx context.Context, args ...any) context.Context {
+	return WithContext(ctx, FromContext(ctx).With(args...))
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestId)
+}
+
+// RequestID returns the request ID of ctx, empty when absent.
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestId, _ := ctx.Value(requestIDKey).(string)
+	return requestId
+}
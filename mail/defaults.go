@@ -0,0 +1,86 @@
+package mail
+
+// Names of the templates of NewDefaultTemplates, rendered with LinkData.
+const (
+	TemplateUserActivation = "user-activation"
+	TemplatePasswordReset  = "password-reset"
+)
+
+// LinkData is the data of the default templates: the name of the user and the link to follow.
+type LinkData struct {
+	Name string
+	Link string
+}
+
+// DefaultLayout is the layout of the default templates.
+var DefaultLayout = Layout{
+	HTML: `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<style>
+		@import url("https://fonts.googleapis.com/css?family=Roboto");
+		body { font-family: "Roboto"; padding: 2rem; }
+		.button { padding: 8px 16px; background-color: #0063f7; color: white; border: none; border-radius: 8px; text-decoration: none; }
+	</style>
+</head>
+<body>
+	<div><img src="https://assets.tagsamurai.com/img/logo-ipsum.png" width="168" /></div>
+	{{template "content" .}}
+	<p><small>This is an autogenerated email. Please do not reply this email.</small></p>
+</body>
+</html>`,
+	Text: `{{template "content" .}}
+
+This is an autogenerated email. Please do not reply this email.
+`,
+}
+
+var defaultTemplates = map[string]Template{
+	TemplateUserActivation: {
+		Subject: "Activate Your Account",
+		HTML: `<p>Hello {{.Name}},</p>
+	<p>Please click the button below to activate your account.</p>
+	<a href="{{.Link}}" class="button">Activate Account</a>
+	<p>If the button does not working properly, please copy the link below and paste to your browser:</p>
+	<a href="{{.Link}}">{{.Link}}</a>
+	<p>This link will be expired in 24 hours since you received this email.</p>`,
+		Text: `Hello {{.Name}},
+
+Please open the link below to activate your account:
+{{.Link}}
+
+This link will be expired in 24 hours since you received this email.`,
+	},
+	TemplatePasswordReset: {
+		Subject: "Reset Your Password",
+		HTML: `<p>Hello {{.Name}},</p>
+	<p>We received a request to reset your password. Please click the button below to choose a new password.</p>
+	<a href="{{.Link}}" class="button">Reset Password</a>
+	<p>If the button does not working properly, please copy the link below and paste to your browser:</p>
+	<a href="{{.Link}}">{{.Link}}</a>
+	<p>This link will be expired in 1 hour since you received this email. If you did not request a password reset, you can ignore this email.</p>`,
+		Text: `Hello {{.Name}},
+
+We received a request to reset your password. Please open the link below to choose a new password:
+{{.Link}}
+
+This link will be expired in 1 hour since you received this email. If you did not request a password reset, you can ignore this email.`,
+	},
+}
+
+// NewDefaultTemplates returns templates with DefaultLayout and the user activation and password reset emails.
+// More templates can be added, and these replaced, with Add.
+func NewDefaultTemplates() *Templates {
+	templates, err := NewTemplates(DefaultLayout)
+	if err != nil {
+		panic(err)
+	}
+
+	for name, tpl := range defaultTemplates {
+		if err := templates.Add(name, tpl); err != nil {
+			panic(err)
+		}
+	}
+
+	return templates
+}
@@ -0,0 +1,131 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+)
+
+var (
+	ErrNoRecipients = errors.New("mail: message has no recipients")
+	ErrNoBody       = errors.New("mail: message has no body")
+)
+
+// Message is an email. HTML and Text are alternatives of the same body, mail clients show the best they support.
+type Message struct {
+	// Defaults to the sender of the Mailer.
+	From    string
+	To      []string
+	Cc      []string
+	Bcc     []string
+	ReplyTo string
+	Subject string
+	HTML    string
+	Text    string
+
+	Attachments []Attachment
+}
+
+type Attachment struct {
+	Filename string
+	// Defaults to the type of the extension of Filename.
+	ContentType string
+	Data        []byte
+}
+
+// Mailer sends emails. Implemented by SMTPMailer, FileMailer and MemoryMailer.
+type Mailer interface {
+	// Send sends msg, returning why it could not be sent.
+	Send(ctx context.Context, msg Message) error
+}
+
+// validate rejects messages no mailer could deliver.
+func (m Message) validate() error {
+	if len(m.To)+len(m.Cc)+len(m.Bcc) == 0 {
+		return ErrNoRecipients
+	}
+	if m.HTML == "" && m.Text == "" {
+		return ErrNoBody
+	}
+
+	return nil
+}
+
+var (
+	defaultMailer Mailer
+	defaultMu     sync.Mutex
+)
+
+// Default returns the mailer of the application, created from env vars by FromEnv on first use.
+// Unlike storage.Default it never panics: when the env vars are invalid, the returned mailer
+// fails every Send with the configuration error, so a broken mail setup does not stop the server.
+func Default() Mailer {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultMailer == nil {
+		mailer, err := FromEnv()
+		if err != nil {
+			return failingMailer{err: err}
+		}
+		defaultMailer = mailer
+	}
+
+	return defaultMailer
+}
+
+// SetDefault replaces the mailer of the application, e.g. a MemoryMailer in tests.
+func SetDefault(mailer Mailer) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	defaultMailer = mailer
+}
+
+// FromEnv creates a mailer from env vars, read once instead of on every email.
+//
+//   - MAILER_DRIVER: "smtp" (default), "file" or "memory"
+//   - MAILER_HOST, MAILER_PORT, MAILER_USER and MAILER_PASSWORD: "smtp"
+//   - MAILER_FROM: sender of the emails, defaults to MAILER_USER
+//   - MAILER_DIR: directory of "file", defaults to "../acts-mails"
+func FromEnv() (Mailer, error) {
+	from := os.Getenv("MAILER_FROM")
+	if from == "" {
+		from = os.Getenv("MAILER_USER")
+	}
+
+	switch driver := os.Getenv("MAILER_DRIVER"); driver {
+	case "", "smtp":
+		port, err := strconv.Atoi(os.Getenv("MAILER_PORT"))
+		if err != nil {
+			return nil, errors.New("mail: invalid MAILER_PORT " + strconv.Quote(os.Getenv("MAILER_PORT")))
+		}
+		return NewSMTPMailer(SMTPOptions{
+			Host:     os.Getenv("MAILER_HOST"),
+			Port:     port,
+			Username: os.Getenv("MAILER_USER"),
+			Password: os.Getenv("MAILER_PASSWORD"),
+			From:     from,
+		})
+	case "file":
+		dir := os.Getenv("MAILER_DIR")
+		if dir == "" {
+			dir = "../acts-mails"
+		}
+		return NewFileMailer(dir, from)
+	case "memory":
+		return NewMemoryMailer(), nil
+	default:
+		return nil, errors.New("mail: unknown MAILER_DRIVER " + driver)
+	}
+}
+
+type failingMailer struct {
+	err error
+}
+
+func (m failingMailer) Send(context.Context, Message) error {
+	return m.err
+}
@@ -0,0 +1,110 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileMailer writes each email to a .eml file of a directory instead of sending it,
+// for local development: the files open in any mail client.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer is a constructor to initialize FileMailer. dir is created when missing,
+// from is the sender of the messages without From.
+func NewFileMailer(dir string, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("mail: creating directory: %w", err)
+	}
+
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	from := m.from
+	if from == "" {
+		// Any sender will do, nothing is delivered.
+		from = "noreply@localhost"
+	}
+	message, err := buildMessage(msg, from)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(m.dir, time.Now().UTC().Format("20060102T150405")+"-*.eml")
+	if err != nil {
+		return fmt.Errorf("mail: creating file: %w", err)
+	}
+
+	if _, err := message.WriteTo(file); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("mail: writing file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("mail: writing file: %w", err)
+	}
+
+	return nil
+}
+
+// MemoryMailer keeps the emails in memory instead of sending them, a stand-in for tests.
+//
+// EXAMPLE:
+//
+//	mailer := mail.NewMemoryMailer()
+//	useCase := credentials.NewCompanyCredentialUseCase(companyCode, credentials.CredentialUseCaseOptions{Mailer: mailer})
+//	_ = useCase.SendActivationEmail(ctx, user, "https://app.example.com/activate")
+//	assert.Equal(t, []string{user.Email}, mailer.Messages()[0].To)
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemoryMailer is a constructor to initialize MemoryMailer
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the emails sent, oldest first.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message{}, m.messages...)
+}
+
+// Reset forgets the emails sent.
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = nil
+}
@@ -0,0 +1,132 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+
+	"gopkg.in/gomail.v2"
+)
+
+type SMTPOptions struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// Sender of the messages without From. Defaults to Username.
+	From string
+}
+
+// SMTPMailer sends emails through an SMTP server, with STARTTLS when the server supports it
+// (implicit TLS on port 465).
+type SMTPMailer struct {
+	dialer *gomail.Dialer
+	from   string
+}
+
+// NewSMTPMailer is a constructor to initialize SMTPMailer
+func NewSMTPMailer(opts SMTPOptions) (*SMTPMailer, error) {
+	if opts.Host == "" {
+		return nil, errors.New("mail: SMTP host is required")
+	}
+	if opts.Port <= 0 {
+		return nil, fmt.Errorf("mail: invalid SMTP port %d", opts.Port)
+	}
+
+	from := opts.From
+	if from == "" {
+		from = opts.Username
+	}
+
+	return &SMTPMailer{
+		dialer: gomail.NewDialer(opts.Host, opts.Port, opts.Username, opts.Password),
+		from:   from,
+	}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	message, err := buildMessage(msg, m.from)
+	if err != nil {
+		return err
+	}
+
+	if err := m.dialer.DialAndSend(message); err != nil {
+		return fmt.Errorf("mail: sending %q: %w", msg.Subject, err)
+	}
+
+	return nil
+}
+
+// buildMessage converts msg to a MIME message, with the text and HTML bodies as alternatives.
+func buildMessage(msg Message, defaultFrom string) (*gomail.Message, error) {
+	from := msg.From
+	if from == "" {
+		from = defaultFrom
+	}
+	if from == "" {
+		return nil, errors.New("mail: message has no sender")
+	}
+
+	message := gomail.NewMessage()
+	message.SetHeader("From", from)
+	if len(msg.To) > 0 {
+		message.SetHeader("To", msg.To...)
+	}
+	if len(msg.Cc) > 0 {
+		message.SetHeader("Cc", msg.Cc...)
+	}
+	if len(msg.Bcc) > 0 {
+		message.SetHeader("Bcc", msg.Bcc...)
+	}
+	if msg.ReplyTo != "" {
+		message.SetHeader("Reply-To", msg.ReplyTo)
+	}
+	message.SetHeader("Subject", msg.Subject)
+
+	// The last alternative is the preferred one.
+	switch {
+	case msg.Text != "" && msg.HTML != "":
+		message.SetBody("text/plain", msg.Text)
+		message.AddAlternative("text/html", msg.HTML)
+	case msg.HTML != "":
+		message.SetBody("text/html", msg.HTML)
+	default:
+		message.SetBody("text/plain", msg.Text)
+	}
+
+	for _, attachment := range msg.Attachments {
+		if attachment.Filename == "" {
+			return nil, errors.New("mail: attachment has no filename")
+		}
+
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(path.Ext(attachment.Filename))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		data := attachment.Data
+		message.Attach(
+			attachment.Filename,
+			gomail.SetHeader(map[string][]string{"Content-Type": {contentType}}),
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(data)
+				return err
+			}),
+		)
+	}
+
+	return message, nil
+}
@@ -0,0 +1,160 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"sync"
+	texttemplate "text/template"
+)
+
+// Layout wraps the body of every template, included with {{template "content" .}}.
+// An empty part only renders the body.
+type Layout struct {
+	HTML string
+	Text string
+}
+
+// Template is an email written with Go templates, executed with the data given to Render.
+// HTML is escaped by html/template, Text is the plain-text alternative. At least one body is required.
+type Template struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+type parsedTemplate struct {
+	subject *texttemplate.Template
+	html    *htmltemplate.Template
+	text    *texttemplate.Template
+}
+
+// Templates renders emails inside a shared layout.
+//
+// EXAMPLE:
+//
+//	templates, err := mail.NewTemplates(mail.Layout{
+//		HTML: `<html><body>{{template "content" .}}<p>The Team</p></body></html>`,
+//		Text: "{{template \"content\" .}}\n\nThe Team",
+//	})
+//	err = templates.Add("receipt", mail.Template{
+//		Subject: "Receipt {{.Number}}",
+//		HTML:    `<p>Thank you for your order {{.Number}}.</p>`,
+//		Text:    "Thank you for your order {{.Number}}.",
+//	})
+//	msg, err := templates.Render("receipt", map[string]any{"Number": "INV-001"})
+//	msg.To = []string{customer.Email}
+//	err = mail.Default().Send(ctx, msg)
+type Templates struct {
+	htmlLayout *htmltemplate.Template
+	textLayout *texttemplate.Template
+
+	mu        sync.RWMutex
+	templates map[string]parsedTemplate
+}
+
+const contentTemplate = `{{template "content" .}}`
+
+// NewTemplates is a constructor to initialize Templates
+func NewTemplates(layout Layout) (*Templates, error) {
+	htmlLayout := layout.HTML
+	if htmlLayout == "" {
+		htmlLayout = contentTemplate
+	}
+	textLayout := layout.Text
+	if textLayout == "" {
+		textLayout = contentTemplate
+	}
+
+	html, err := htmltemplate.New("layout").Option("missingkey=error").Parse(htmlLayout)
+	if err != nil {
+		return nil, fmt.Errorf("mail: parsing HTML layout: %w", err)
+	}
+	text, err := texttemplate.New("layout").Option("missingkey=error").Parse(textLayout)
+	if err != nil {
+		return nil, fmt.Errorf("mail: parsing text layout: %w", err)
+	}
+
+	return &Templates{
+		htmlLayout: html,
+		textLayout: text,
+		templates:  map[string]parsedTemplate{},
+	}, nil
+}
+
+// Add parses tpl and registers it as name, replacing any template with the same name.
+func (t *Templates) Add(name string, tpl Template) error {
+	if tpl.HTML == "" && tpl.Text == "" {
+		return fmt.Errorf("mail: template %q has no body", name)
+	}
+
+	parsed := parsedTemplate{}
+	var err error
+
+	parsed.subject, err = texttemplate.New("subject").Option("missingkey=error").Parse(tpl.Subject)
+	if err != nil {
+		return fmt.Errorf("mail: parsing subject of %q: %w", name, err)
+	}
+
+	if tpl.HTML != "" {
+		parsed.html, err = t.htmlLayout.Clone()
+		if err == nil {
+			_, err = parsed.html.New("content").Parse(tpl.HTML)
+		}
+		if err != nil {
+			return fmt.Errorf("mail: parsing HTML of %q: %w", name, err)
+		}
+	}
+
+	if tpl.Text != "" {
+		parsed.text, err = t.textLayout.Clone()
+		if err == nil {
+			_, err = parsed.text.New("content").Parse(tpl.Text)
+		}
+		if err != nil {
+			return fmt.Errorf("mail: parsing text of %q: %w", name, err)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.templates[name] = parsed
+	return nil
+}
+
+// Render executes the template name with data, returning a message without recipients.
+func (t *Templates) Render(name string, data any) (Message, error) {
+	t.mu.RLock()
+	parsed, ok := t.templates[name]
+	t.mu.RUnlock()
+	if !ok {
+		return Message{}, fmt.Errorf("mail: unknown template %q", name)
+	}
+
+	msg := Message{}
+	var buf bytes.Buffer
+
+	if err := parsed.subject.Execute(&buf, data); err != nil {
+		return Message{}, fmt.Errorf("mail: rendering subject of %q: %w", name, err)
+	}
+	msg.Subject = buf.String()
+
+	if parsed.html != nil {
+		buf.Reset()
+		if err := parsed.html.Execute(&buf, data); err != nil {
+			return Message{}, fmt.Errorf("mail: rendering HTML of %q: %w", name, err)
+		}
+		msg.HTML = buf.String()
+	}
+
+	if parsed.text != nil {
+		buf.Reset()
+		if err := parsed.text.Execute(&buf, data); err != nil {
+			return Message{}, fmt.Errorf("mail: rendering text of %q: %w", name, err)
+		}
+		msg.Text = buf.String()
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/susatyo441/go-ta-utils/entity"
+	"github.com/susatyo441/go-ta-utils/model"
+)
+
+// APIKeyAuthenticator resolves a full API key, see service.IAPIKeyUseCase.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string, ip string) (*model.APIKey, *entity.HttpError)
+}
+
+type APIKeyOptions struct {
+	// Header carrying the key. Defaults to "X-API-Key".
+	// "Authorization: ApiKey <key>" is always accepted.
+	Header string
+}
+
+func determineAPIKeyOptions(opts ...APIKeyOptions) APIKeyOptions {
+	actualOpts := APIKeyOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.Header == "" {
+		actualOpts.Header = "X-API-Key"
+	}
+
+	return actualOpts
+}
+
+// Middleware to authenticate machine clients (POS terminals, reporting jobs) by API key.
+//
+// Sets UserKey and StoreKey like ValidateJWT, so handlers work with both, and APIKeyKey (*model.APIKey).
+// RequirePermission checks the key scopes instead of the user roles.
+//
+// EXAMPLE:
+//
+//	apiKeys := service.NewCompanyAPIKeyUseCase(companyCode)
+//	app.Post("/transaction", middleware.ValidateAPIKey(apiKeys), middleware.RequirePermission("transaction:create"), handler)
+func ValidateAPIKey(authenticator APIKeyAuthenticator, opts ...APIKeyOptions) fiber.Handler {
+	actualOpts := determineAPIKeyOptions(opts...)
+
+	return func(ctx *fiber.Ctx) error {
+		key := ctx.Get(actualOpts.Header)
+		if key == "" {
+			if value, found := strings.CutPrefix(ctx.Get("Authorization"), "ApiKey "); found {
+				key = value
+			}
+		}
+		if key == "" {
+			return fiber.NewError(fiber.StatusUnauthorized, "Unauthorized")
+		}
+
+		apiKey, err := authenticator.AuthenticateAPIKey(ctx.UserContext(), strings.TrimSpace(key), ctx.IP())
+		if err != nil {
+			return fiber.NewError(err.Code, err.Message)
+		}
+
+		ctx.Locals(UserKey, apiKey.UserID)
+		ctx.Locals(StoreKey, apiKey.StoreID)
+		ctx.Locals(APIKeyKey, apiKey)
+		addLogFields(ctx, "user_id", apiKey.UserID.Hex(), "store_id", apiKey.StoreID.Hex(), "api_key", apiKey.Prefix)
+		return ctx.Next()
+	}
+}
+
+// CurrentAPIKey returns the API key of a request authenticated by ValidateAPIKey.
+func CurrentAPIKey(ctx *fiber.Ctx) (*model.APIKey, bool) {
+	apiKey, ok := ctx.Locals(APIKeyKey).(*model.APIKey)
+	return apiKey, ok && apiKey != nil
+}
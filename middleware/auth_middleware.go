@@ -1,19 +1,58 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"github.com/golang-jwt/jwt/v5"
 )
 
+type JWTOptions struct {
+	// Keys used to verify tokens, looked up by the "kid" header.
+	// Defaults to a HMAC key set from Key, see NewHMACKeySet.
+	Keys *KeySet
+	// HMAC key, used when Keys is nil. Defaults to the JWT_KEY env var, read on the first requests
+	// so routes can be registered before the env is loaded. Requests are answered 500 while it is not set.
+	Key []byte
+	// Expected claims, see ClaimsValidation.
+	// Issuer and Audience default to the JWT_ISSUER and JWT_AUDIENCE env vars.
+	Validation ClaimsValidation
+	// Rejects tokens whose "session" is revoked or expired, see NewCachedSessionChecker.
+	// Not checked when nil.
+	Sessions SessionChecker
+}
+
+func determineJWTOptions(opts ...JWTOptions) JWTOptions {
+	actualOpts := JWTOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.Validation.Issuer == "" {
+		actualOpts.Validation.Issuer = os.Getenv("JWT_ISSUER")
+	}
+	if actualOpts.Validation.Audience == "" {
+		actualOpts.Validation.Audience = os.Getenv("JWT_AUDIENCE")
+	}
+
+	return actualOpts
+}
+
 // Middleware to validate JWT
-func ValidateJWT() fiber.Handler {
+//
+// Accepts HMAC (HS*), RSA (RS*, PS*), ECDSA (ES*) and EdDSA tokens signed by a key of JWTOptions.Keys.
+// Sets UserKey, SessionKey and StoreKey (primitive.ObjectID) and ClaimsKey (*Claims),
+// read them with CurrentUser, CurrentSession, CurrentStore and CurrentClaims.
+func ValidateJWT(opts ...JWTOptions) fiber.Handler {
+	actualOpts := determineJWTOptions(opts...)
+	keySource := &hmacKeySource{keys: actualOpts.Keys, secret: actualOpts.Key}
+
 	return func(ctx *fiber.Ctx) error {
-		var jwtKey = []byte(os.Getenv("JWT_KEY"))
 		tokenStr := ctx.Get("Authorization", "NOT_FOUND")
 		if tokenStr == "NOT_FOUND" {
 			return fiber.NewError(fiber.StatusUnauthorized, "Unauthorized")
@@ -22,33 +61,119 @@ func ValidateJWT() fiber.Handler {
 		// Extract the actual token by removing "Bearer " prefix
 		tokenStr = strings.Replace(tokenStr, "Bearer ", "", 1)
 
-		// Parse and validate the JWT
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			// Validate the algorithm used
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return jwtKey, nil
-		})
+		keys, err := keySource.get()
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Error verifying token: "+err.Error())
+		}
 
+		claims, err := ParseToken(tokenStr, keys, actualOpts.Validation)
 		if err != nil {
-			return fiber.NewError(fiber.StatusUnauthorized, "Unauthorized")
+			return fiber.NewError(fiber.StatusUnauthorized, "Unauthorized: "+err.Error())
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		if actualOpts.Sessions != nil {
+			if claims.SessionID.IsZero() {
+				return fiber.NewError(fiber.StatusUnauthorized, "Unauthorized: token has no session")
+			}
 
-			userId, _ := primitive.ObjectIDFromHex(claims["id"].(string))
-			session, _ := primitive.ObjectIDFromHex(claims["session"].(string))
-			store, _ := primitive.ObjectIDFromHex(claims["store"].(string))
+			active, err := actualOpts.Sessions.IsSessionActive(ctx.UserContext(), claims.SessionID)
+			if err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, "Error checking session: "+err.Error())
+			}
+			if !active {
+				return fiber.NewError(fiber.StatusUnauthorized, "Unauthorized: session revoked")
+			}
+		}
 
-			ctx.Locals(UserKey, userId)
-			ctx.Locals(SessionKey, session)
-			ctx.Locals(StoreKey, store)
+		setClaimsLocals(ctx, claims)
 
-		} else {
-			return fiber.NewError(fiber.StatusUnauthorized, "Unauthorized")
+		return ctx.Next()
+	}
+}
+
+// hmacKeySource returns the keys of ValidateJWT, creating the HMAC key set on first use.
+// A failure is not kept, so the key set is created once JWT_KEY is set.
+type hmacKeySource struct {
+	mu     sync.Mutex
+	keys   *KeySet
+	secret []byte
+}
+
+func (s *hmacKeySource) get() (*KeySet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.keys != nil {
+		return s.keys, nil
+	}
+
+	secret := s.secret
+	if secret == nil {
+		secret = []byte(os.Getenv("JWT_KEY"))
+	}
+	keys, err := NewHMACKeySet(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	s.keys = keys
+	return keys, nil
+}
+
+// ParseToken verifies the signature of tokenStr with keys and validates its claims.
+func ParseToken(tokenStr string, keys *KeySet, validation ClaimsValidation) (*Claims, error) {
+	// Claims are validated below, with leeway
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+
+	token, err := parser.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		key, exist := keys.Lookup(kid)
+		if !exist {
+			return nil, fmt.Errorf("unknown key %q", kid)
 		}
 
-		return ctx.Next()
+		// Validate the algorithm used, so a public key cannot be used as an HMAC secret
+		if !key.Accepts(token.Method.Alg()) {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key.PublicKey, nil
+	})
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+
+	claims, err := ClaimsFromMap(mapClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := claims.Validate(time.Now(), validation); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// setClaimsLocals stores the claims in the context locals read by the Current* accessors.
+func setClaimsLocals(ctx *fiber.Ctx, claims *Claims) {
+	ctx.Locals(UserKey, claims.UserID)
+	ctx.Locals(SessionKey, claims.SessionID)
+	ctx.Locals(StoreKey, claims.StoreID)
+	ctx.Locals(ClaimsKey, claims)
+
+	logFields := []any{"user_id", claims.UserID.Hex()}
+	if !claims.StoreID.IsZero() {
+		logFields = append(logFields, "store_id", claims.StoreID.Hex())
 	}
+	addLogFields(ctx, logFields...)
 }
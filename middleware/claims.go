@@ -0,0 +1,277 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Claims are the typed claims of our access tokens.
+//
+// Claim names: "id" (user), "session", "store", "company", "roles", "exp", "nbf", "iat", "iss", "aud".
+type Claims struct {
+	UserID    primitive.ObjectID
+	SessionID primitive.ObjectID
+	StoreID   primitive.ObjectID
+	// Tenant code, see ResolveTenant.
+	CompanyCode string
+	Roles       []string
+	ExpiresAt   time.Time
+	NotBefore   time.Time
+	IssuedAt    time.Time
+	Issuer      string
+	Audience    []string
+}
+
+// ClaimsValidation configures Claims.Validate.
+type ClaimsValidation struct {
+	// Expected "iss". Not checked when empty.
+	Issuer string
+	// Expected "aud", the token must contain it. Not checked when empty.
+	Audience string
+	// Allowed clock skew for "exp" and "nbf".
+	Leeway time.Duration
+}
+
+// ClaimsFromMap reads claims from a decoded token payload without panicking on missing or mistyped claims.
+// "id" is required, "session" and "store" are optional but must be valid ObjectIDs when present.
+func ClaimsFromMap(raw map[string]interface{}) (*Claims, error) {
+	claims := &Claims{}
+	var err error
+
+	if claims.UserID, err = objectIDClaim(raw, "id", true); err != nil {
+		return nil, err
+	}
+	if claims.SessionID, err = objectIDClaim(raw, "session", false); err != nil {
+		return nil, err
+	}
+	if claims.StoreID, err = objectIDClaim(raw, "store", false); err != nil {
+		return nil, err
+	}
+	if claims.Roles, err = stringsClaim(raw, "roles"); err != nil {
+		return nil, err
+	}
+	if claims.Audience, err = stringsClaim(raw, "aud"); err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt, err = timeClaim(raw, "exp"); err != nil {
+		return nil, err
+	}
+	if claims.NotBefore, err = timeClaim(raw, "nbf"); err != nil {
+		return nil, err
+	}
+	if claims.IssuedAt, err = timeClaim(raw, "iat"); err != nil {
+		return nil, err
+	}
+	if claims.Issuer, err = stringClaim(raw, "iss"); err != nil {
+		return nil, err
+	}
+	if claims.CompanyCode, err = stringClaim(raw, "company"); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// ToMap returns the claims as a token payload, the inverse of ClaimsFromMap.
+func (c *Claims) ToMap() map[string]interface{} {
+	raw := map[string]interface{}{
+		"id": c.UserID.Hex(),
+	}
+
+	if !c.SessionID.IsZero() {
+		raw["session"] = c.SessionID.Hex()
+	}
+	if !c.StoreID.IsZero() {
+		raw["store"] = c.StoreID.Hex()
+	}
+	if c.CompanyCode != "" {
+		raw["company"] = c.CompanyCode
+	}
+	if len(c.Roles) > 0 {
+		raw["roles"] = c.Roles
+	}
+	if len(c.Audience) > 0 {
+		raw["aud"] = c.Audience
+	}
+	if c.Issuer != "" {
+		raw["iss"] = c.Issuer
+	}
+	if !c.ExpiresAt.IsZero() {
+		raw["exp"] = c.ExpiresAt.Unix()
+	}
+	if !c.NotBefore.IsZero() {
+		raw["nbf"] = c.NotBefore.Unix()
+	}
+	if !c.IssuedAt.IsZero() {
+		raw["iat"] = c.IssuedAt.Unix()
+	}
+
+	return raw
+}
+
+// Validate checks "exp" (required), "nbf", "iss" and "aud" at now.
+func (c *Claims) Validate(now time.Time, validation ClaimsValidation) error {
+	if c.ExpiresAt.IsZero() {
+		return errors.New("token has no expiry")
+	}
+	if now.After(c.ExpiresAt.Add(validation.Leeway)) {
+		return errors.New("token is expired")
+	}
+	if !c.NotBefore.IsZero() && now.Add(validation.Leeway).Before(c.NotBefore) {
+		return errors.New("token is not valid yet")
+	}
+	if validation.Issuer != "" && c.Issuer != validation.Issuer {
+		return errors.New("invalid token issuer")
+	}
+	if validation.Audience != "" && !c.HasAudience(validation.Audience) {
+		return errors.New("invalid token audience")
+	}
+
+	return nil
+}
+
+// HasAudience reports whether the token is intended for audience.
+func (c *Claims) HasAudience(audience string) bool {
+	for _, aud := range c.Audience {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the token carries role.
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func objectIDClaim(raw map[string]interface{}, name string, required bool) (primitive.ObjectID, error) {
+	value, exist := raw[name]
+	if !exist || value == nil {
+		if required {
+			return primitive.NilObjectID, fmt.Errorf("missing %s claim", name)
+		}
+		return primitive.NilObjectID, nil
+	}
+
+	hex, ok := value.(string)
+	if !ok {
+		return primitive.NilObjectID, fmt.Errorf("invalid %s claim", name)
+	}
+
+	oid, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("invalid %s claim: %w", name, err)
+	}
+
+	return oid, nil
+}
+
+func stringClaim(raw map[string]interface{}, name string) (string, error) {
+	value, exist := raw[name]
+	if !exist || value == nil {
+		return "", nil
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid %s claim", name)
+	}
+
+	return str, nil
+}
+
+// stringsClaim reads a claim that is either a string or a list of strings.
+func stringsClaim(raw map[string]interface{}, name string) ([]string, error) {
+	switch value := raw[name].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{value}, nil
+	case []string:
+		return value, nil
+	case []interface{}:
+		result := make([]string, 0, len(value))
+		for _, item := range value {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s claim", name)
+			}
+			result = append(result, str)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("invalid %s claim", name)
+	}
+}
+
+// timeClaim reads a NumericDate claim (seconds since epoch).
+func timeClaim(raw map[string]interface{}, name string) (time.Time, error) {
+	var seconds float64
+
+	switch value := raw[name].(type) {
+	case nil:
+		return time.Time{}, nil
+	case float64:
+		seconds = value
+	case int64:
+		seconds = float64(value)
+	case int:
+		seconds = float64(value)
+	case json.Number:
+		parsed, err := value.Float64()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s claim", name)
+		}
+		seconds = parsed
+	default:
+		return time.Time{}, fmt.Errorf("invalid %s claim", name)
+	}
+
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*1e9)), nil
+}
+
+// CurrentClaims returns the claims set by ValidateJWT.
+func CurrentClaims(ctx *fiber.Ctx) (*Claims, bool) {
+	claims, ok := ctx.Locals(ClaimsKey).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// CurrentUser returns the authenticated user id.
+//
+// EXAMPLE:
+//
+//	userId, ok := middleware.CurrentUser(ctx)
+//	if !ok {
+//		return entity.Unauthorized("Unauthorized").SendResponse(ctx)
+//	}
+func CurrentUser(ctx *fiber.Ctx) (primitive.ObjectID, bool) {
+	return objectIDLocal(ctx, UserKey)
+}
+
+// CurrentSession returns the session id of the authenticated request.
+func CurrentSession(ctx *fiber.Ctx) (primitive.ObjectID, bool) {
+	return objectIDLocal(ctx, SessionKey)
+}
+
+// CurrentStore returns the store of the authenticated request.
+func CurrentStore(ctx *fiber.Ctx) (primitive.ObjectID, bool) {
+	return objectIDLocal(ctx, StoreKey)
+}
+
+func objectIDLocal(ctx *fiber.Ctx, key ContextKey) (primitive.ObjectID, bool) {
+	oid, ok := ctx.Locals(key).(primitive.ObjectID)
+	return oid, ok && !oid.IsZero()
+}
@@ -8,4 +8,9 @@ const (
 	CompanyCodeKey = ContextKey("companyCode")
 	SessionKey     = ContextKey("session")
 	StoreKey       = ContextKey("store")
+	ClaimsKey      = ContextKey("claims")
+	RolesKey       = ContextKey("roles")
+	APIKeyKey      = ContextKey("apiKey")
+	TenantKey      = ContextKey("tenant")
+	RequestIDKey   = ContextKey("requestId")
 )
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/susatyo441/go-ta-utils/entity"
+	"github.com/susatyo441/go-ta-utils/model"
+)
+
+// Keys are client generated (usually UUIDs), longer ones are rejected.
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyStore keeps the outcome of requests per key. Implemented by service.IIdempotencyUseCase.
+type IdempotencyStore interface {
+	BeginIdempotentRequest(ctx context.Context, scope string, key string, fingerprint string) (*model.IdempotencyRecord, error)
+	CompleteIdempotentRequest(ctx context.Context, scope string, key string, response model.IdempotencyResponse) error
+	ReleaseIdempotentRequest(ctx context.Context, scope string, key string) error
+}
+
+type IdempotencyOptions struct {
+	// Defaults to "Idempotency-Key".
+	Header string
+	// Reject requests without the header. By default they are processed normally.
+	Required bool
+}
+
+func determineIdempotencyOptions(opts ...IdempotencyOptions) IdempotencyOptions {
+	actualOpts := IdempotencyOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.Header == "" {
+		actualOpts.Header = "Idempotency-Key"
+	}
+
+	return actualOpts
+}
+
+// Middleware to make a mutating endpoint safe to retry.
+//
+// The first request with a key is processed and its response stored; retries with the same key
+// and the same request get the stored response back with "Idempotent-Replayed: true".
+// Keys are scoped per store (StoreKey), or per user when there is no store, so place it after ValidateJWT / ValidateAPIKey.
+//
+//   - same key, different method, path or body: 422
+//   - same key while the first request is still processing: 409
+//   - failed requests (error or 5xx) are not stored, so they can be retried
+//
+// EXAMPLE:
+//
+//	idempotency := service.NewCompanyIdempotencyUseCase(companyCode)
+//	app.Post("/transactions", middleware.ValidateJWT(), middleware.Idempotency(idempotency), handler)
+func Idempotency(store IdempotencyStore, opts ...IdempotencyOptions) fiber.Handler {
+	actualOpts := determineIdempotencyOptions(opts...)
+
+	return func(ctx *fiber.Ctx) error {
+		key := ctx.Get(actualOpts.Header)
+		if key == "" {
+			if actualOpts.Required {
+				return entity.BadRequest("Missing " + actualOpts.Header + " header").SendResponse(ctx)
+			}
+			return ctx.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return entity.BadRequest(actualOpts.Header + " is too long").SendResponse(ctx)
+		}
+
+		scope := idempotencyScope(ctx)
+		fingerprint := requestFingerprint(ctx)
+
+		existing, err := store.BeginIdempotentRequest(ctx.UserContext(), scope, key, fingerprint)
+		if err != nil {
+			return entity.InternalServerError("Error checking idempotency key: " + err.Error()).SendResponse(ctx)
+		}
+
+		if existing != nil {
+			if existing.Fingerprint != fingerprint {
+				return entity.UnprocessableEntity(actualOpts.Header + " was already used for a different request").SendResponse(ctx)
+			}
+			if existing.Status != model.IdempotencyCompleted || existing.Response == nil {
+				return entity.Conflict("A request with this " + actualOpts.Header + " is still being processed").SendResponse(ctx)
+			}
+
+			ctx.Set("Idempotent-Replayed", "true")
+			if existing.Response.ContentType != "" {
+				ctx.Set(fiber.HeaderContentType, existing.Response.ContentType)
+			}
+			return ctx.Status(existing.Response.StatusCode).Send(existing.Response.Body)
+		}
+
+		if err := ctx.Next(); err != nil {
+			releaseIdempotencyKey(store, ctx, scope, key)
+			return err
+		}
+
+		statusCode := ctx.Response().StatusCode()
+		if statusCode >= fiber.StatusInternalServerError {
+			releaseIdempotencyKey(store, ctx, scope, key)
+			return nil
+		}
+
+		response := model.IdempotencyResponse{
+			StatusCode:  statusCode,
+			ContentType: string(ctx.Response().Header.ContentType()),
+			Body:        append([]byte(nil), ctx.Response().Body()...),
+		}
+		if err := store.CompleteIdempotentRequest(ctx.UserContext(), scope, key, response); err != nil {
+			// The request succeeded, only its replay is lost.
+			Logger(ctx).Error("Failed to store idempotent response", "error", err)
+		}
+
+		return nil
+	}
+}
+
+func idempotencyScope(ctx *fiber.Ctx) string {
+	if storeId, ok := CurrentStore(ctx); ok {
+		return "store:" + storeId.Hex()
+	}
+	if userId, ok := CurrentUser(ctx); ok {
+		return "user:" + userId.Hex()
+	}
+	return "anonymous"
+}
+
+func requestFingerprint(ctx *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(ctx.Method()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(ctx.OriginalURL()))
+	hash.Write([]byte{0})
+	hash.Write(ctx.Body())
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func releaseIdempotencyKey(store IdempotencyStore, ctx *fiber.Ctx, scope string, key string) {
+	if err := store.ReleaseIdempotentRequest(ctx.UserContext(), scope, key); err != nil {
+		Logger(ctx).Error("Failed to release idempotency key", "error", err)
+	}
+}
@@ -0,0 +1,476 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/susatyo441/go-ta-utils/logger"
+)
+
+// JWTKey is a single key of a KeySet.
+type JWTKey struct {
+	// "kid" header of the tokens signed with this key.
+	ID string
+	// JWT "alg", e.g. HS256, RS256, ES256 or EdDSA.
+	Algorithm string
+	// Other "alg" accepted when verifying, of the same family as Algorithm (e.g. HS384 and HS512 for a HS256 secret).
+	// Tokens are always signed with Algorithm.
+	AcceptedAlgorithms []string
+	// Verification key: []byte, *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+	PublicKey interface{}
+	// Signing key, nil for verification-only keys: []byte, *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey.
+	PrivateKey interface{}
+}
+
+// KeySource loads the keys of a KeySet, in priority order.
+type KeySource func() ([]JWTKey, error)
+
+// KeySet holds the keys used to verify and issue tokens, looked up by "kid".
+// Keys can be replaced at runtime (Reload, Watch) so they can be rotated without downtime:
+// publish the new key next to the old one, switch signing to it, then drop the old key
+// once the tokens it signed have expired.
+type KeySet struct {
+	mu         sync.RWMutex
+	keys       map[string]JWTKey
+	signingKey *JWTKey
+	signingKid string
+}
+
+// NewKeySet creates a key set from keys.
+// The signing key is the first key having a PrivateKey, see SetSigningKeyID to override it.
+func NewKeySet(keys ...JWTKey) *KeySet {
+	ks := &KeySet{}
+	ks.replace(keys)
+	return ks
+}
+
+// NewHMACKeySet creates a key set with a single HMAC secret, used for tokens without "kid".
+// Tokens are signed with HS256, and HS384 and HS512 tokens are accepted as well, as before key sets.
+// An empty secret is rejected, since it would accept tokens anyone can sign.
+func NewHMACKeySet(secret []byte) (*KeySet, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("JWT HMAC secret is empty, set JWT_KEY")
+	}
+
+	return NewKeySet(JWTKey{
+		Algorithm:          "HS256",
+		AcceptedAlgorithms: []string{"HS384", "HS512"},
+		PublicKey:          secret,
+		PrivateKey:         secret,
+	}), nil
+}
+
+// Accepts reports whether tokens signed with alg can be verified with the key.
+func (k JWTKey) Accepts(alg string) bool {
+	if alg == k.Algorithm {
+		return true
+	}
+
+	for _, accepted := range k.AcceptedAlgorithms {
+		if alg == accepted {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadKeySet creates a key set from source.
+//
+// EXAMPLE:
+//
+//	keys, err := middleware.LoadKeySet(middleware.PEMDir("/etc/acts/jwt"))
+//	go keys.Watch(ctx, middleware.PEMDir("/etc/acts/jwt"), time.Minute)
+//	app.Use(middleware.ValidateJWT(middleware.JWTOptions{Keys: keys}))
+func LoadKeySet(source KeySource) (*KeySet, error) {
+	keys, err := source()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeySet(keys...), nil
+}
+
+// Reload replaces the keys with the ones from source. The current keys are kept on error.
+func (ks *KeySet) Reload(source KeySource) error {
+	keys, err := source()
+	if err != nil {
+		return err
+	}
+
+	ks.replace(keys)
+	return nil
+}
+
+// Watch reloads the keys from source every interval until ctx is done.
+// Reload failures are logged and the current keys are kept.
+func (ks *KeySet) Watch(ctx context.Context, source KeySource, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ks.Reload(source); err != nil {
+				logger.FromContext(ctx).Error("Failed to reload JWT keys", "error", err)
+			}
+		}
+	}
+}
+
+// SetSigningKeyID selects the key used by IssueToken. It stays selected across reloads.
+func (ks *KeySet) SetSigningKeyID(kid string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	key, exist := ks.keys[kid]
+	if !exist || key.PrivateKey == nil {
+		return fmt.Errorf("no private key with kid %q", kid)
+	}
+
+	ks.signingKid = kid
+	ks.signingKey = &key
+	return nil
+}
+
+// Lookup returns the key for kid. A token without "kid" matches a key without ID,
+// or the only key of the set.
+func (ks *KeySet) Lookup(kid string) (JWTKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if key, exist := ks.keys[kid]; exist {
+		return key, true
+	}
+
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+
+	return JWTKey{}, false
+}
+
+// SigningKey returns the key used by IssueToken.
+func (ks *KeySet) SigningKey() (JWTKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if ks.signingKey == nil {
+		return JWTKey{}, false
+	}
+	return *ks.signingKey, true
+}
+
+func (ks *KeySet) replace(keys []JWTKey) {
+	byID := make(map[string]JWTKey, len(keys))
+	var signingKey *JWTKey
+
+	for _, key := range keys {
+		byID[key.ID] = key
+		if signingKey == nil && key.PrivateKey != nil {
+			selected := key
+			signingKey = &selected
+		}
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if selected, exist := byID[ks.signingKid]; ks.signingKid != "" && exist && selected.PrivateKey != nil {
+		signingKey = &selected
+	}
+
+	ks.keys = byID
+	ks.signingKey = signingKey
+}
+
+// jwk is a JSON Web Key (RFC 7517), only the members we use.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	D   string `json:"d"`
+	P   string `json:"p"`
+	Q   string `json:"q"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// JWKSFile loads keys from a JSON Web Key Set file ({"keys": [...]}).
+// Supports RSA, EC (P-256, P-384, P-521), OKP (Ed25519) and oct keys, public or private.
+func JWKSFile(path string) KeySource {
+	return func() ([]JWTKey, error) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var set struct {
+			Keys []jwk `json:"keys"`
+		}
+		if err := json.Unmarshal(content, &set); err != nil {
+			return nil, fmt.Errorf("invalid JWKS file %s: %w", path, err)
+		}
+
+		keys := make([]JWTKey, 0, len(set.Keys))
+		for _, raw := range set.Keys {
+			key, err := raw.toJWTKey()
+			if err != nil {
+				return nil, fmt.Errorf("invalid key %q in %s: %w", raw.Kid, path, err)
+			}
+			keys = append(keys, key)
+		}
+
+		return keys, nil
+	}
+}
+
+func (k jwk) toJWTKey() (JWTKey, error) {
+	key := JWTKey{ID: k.Kid, Algorithm: k.Alg}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return key, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return key, err
+		}
+		public := &rsa.PublicKey{N: n, E: int(e.Int64())}
+		key.PublicKey = public
+
+		if k.D != "" {
+			d, err := decodeBigInt(k.D)
+			if err != nil {
+				return key, err
+			}
+			p, err := decodeBigInt(k.P)
+			if err != nil {
+				return key, err
+			}
+			q, err := decodeBigInt(k.Q)
+			if err != nil {
+				return key, err
+			}
+			private := &rsa.PrivateKey{PublicKey: *public, D: d, Primes: []*big.Int{p, q}}
+			if err := private.Validate(); err != nil {
+				return key, err
+			}
+			private.Precompute()
+			key.PrivateKey = private
+		}
+
+	case "EC":
+		curve, err := curveByName(k.Crv)
+		if err != nil {
+			return key, err
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return key, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return key, err
+		}
+		public := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		key.PublicKey = public
+
+		if k.D != "" {
+			d, err := decodeBigInt(k.D)
+			if err != nil {
+				return key, err
+			}
+			key.PrivateKey = &ecdsa.PrivateKey{PublicKey: *public, D: d}
+		}
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return key, fmt.Errorf("unsupported OKP curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return key, errors.New("invalid Ed25519 public key")
+		}
+		key.PublicKey = ed25519.PublicKey(x)
+
+		if k.D != "" {
+			seed, err := base64.RawURLEncoding.DecodeString(k.D)
+			if err != nil || len(seed) != ed25519.SeedSize {
+				return key, errors.New("invalid Ed25519 private key")
+			}
+			key.PrivateKey = ed25519.NewKeyFromSeed(seed)
+		}
+
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return key, err
+		}
+		key.PublicKey = secret
+		key.PrivateKey = secret
+
+	default:
+		return key, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+
+	if key.Algorithm == "" {
+		key.Algorithm = defaultAlgorithm(key.PublicKey)
+	}
+
+	return key, nil
+}
+
+// PEMDir loads one key per "<kid>.pem" file of dir. Files may contain a public key,
+// a certificate or a private key (PKCS#1, PKCS#8 or SEC 1).
+//
+// Keys are ordered by descending file name, so with date-based names (2025-06.pem)
+// the newest private key signs new tokens.
+func PEMDir(dir string) KeySource {
+	return func() ([]JWTKey, error) {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+
+		keys := make([]JWTKey, 0, len(paths))
+		for _, path := range paths {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+
+			key, err := parsePEMKey(content)
+			if err != nil {
+				return nil, fmt.Errorf("invalid key %s: %w", path, err)
+			}
+			key.ID = strings.TrimSuffix(filepath.Base(path), ".pem")
+			keys = append(keys, key)
+		}
+
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("no .pem key found in %s", dir)
+		}
+
+		return keys, nil
+	}
+}
+
+func parsePEMKey(content []byte) (JWTKey, error) {
+	key := JWTKey{}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return key, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var certificate *x509.Certificate
+		certificate, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			parsed = certificate.PublicKey
+		}
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return key, fmt.Errorf("unsupported PEM block %s", block.Type)
+	}
+	if err != nil {
+		return key, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.PrivateKey, key.PublicKey = k, &k.PublicKey
+	case *ecdsa.PrivateKey:
+		key.PrivateKey, key.PublicKey = k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.PrivateKey, key.PublicKey = k, k.Public()
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		key.PublicKey = k
+	default:
+		return key, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	key.Algorithm = defaultAlgorithm(key.PublicKey)
+	return key, nil
+}
+
+func defaultAlgorithm(publicKey interface{}) string {
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		return "RS256"
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P384():
+			return "ES384"
+		case elliptic.P521():
+			return "ES512"
+		default:
+			return "ES256"
+		}
+	case ed25519.PublicKey:
+		return "EdDSA"
+	default:
+		return "HS256"
+	}
+}
+
+func curveByName(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported EC curve %s", name)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/susatyo441/go-ta-utils/entity"
+	"github.com/susatyo441/go-ta-utils/logger"
+)
+
+// Incoming request IDs are echoed in logs and headers, so only a safe charset is accepted.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type RequestLoggerOptions struct {
+	// Defaults to logger.Default().
+	Logger *slog.Logger
+	// Header read for an incoming request ID and set on the response. Defaults to "X-Request-ID".
+	Header string
+	// Paths not access-logged, e.g. health checks. Still recovered from panics.
+	SkipPaths []string
+}
+
+func determineRequestLoggerOptions(opts ...RequestLoggerOptions) RequestLoggerOptions {
+	actualOpts := RequestLoggerOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.Logger == nil {
+		actualOpts.Logger = logger.Default()
+	}
+	if actualOpts.Header == "" {
+		actualOpts.Header = "X-Request-ID"
+	}
+
+	return actualOpts
+}
+
+// Middleware to assign a request ID, log the request and recover from panics. Register it first.
+//
+// The request logger (with request_id, method and path, then user_id, store_id and company
+// once authenticated) is put in ctx.UserContext(), read it with Logger(ctx) or logger.FromContext.
+// One access line is logged per request with status and latency. Panics are logged with
+// their stack and answered with a 500 entity.HttpError.
+//
+// EXAMPLE:
+//
+//	logger.SetDefault(logger.New())
+//	app.Use(middleware.RequestLogger())
+//
+//	// in a handler or use case
+//	middleware.Logger(ctx).Info("product created", "product_id", product.ID)
+func RequestLogger(opts ...RequestLoggerOptions) fiber.Handler {
+	actualOpts := determineRequestLoggerOptions(opts...)
+	skipPaths := map[string]bool{}
+	for _, path := range actualOpts.SkipPaths {
+		skipPaths[path] = true
+	}
+
+	return func(ctx *fiber.Ctx) (err error) {
+		start := time.Now()
+
+		requestId := ctx.Get(actualOpts.Header)
+		if !requestIDPattern.MatchString(requestId) {
+			requestId = newRequestID()
+		}
+		ctx.Set(actualOpts.Header, requestId)
+		ctx.Locals(RequestIDKey, requestId)
+
+		requestLogger := actualOpts.Logger.With(
+			"request_id", requestId,
+			"method", ctx.Method(),
+			"path", ctx.Path(),
+		)
+		userCtx := logger.WithRequestID(ctx.UserContext(), requestId)
+		ctx.SetUserContext(logger.WithContext(userCtx, requestLogger))
+
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				Logger(ctx).Error(
+					"panic recovered",
+					"panic", fmt.Sprint(recovered),
+					"stack", string(debug.Stack()),
+				)
+				err = entity.InternalServerError("Internal server error").SendResponse(ctx)
+			}
+
+			if !skipPaths[ctx.Path()] {
+				logAccess(ctx, start)
+			}
+		}()
+
+		if chainErr := ctx.Next(); chainErr != nil {
+			// Render the error now, so the access log has the final status.
+			if handlerErr := ctx.App().ErrorHandler(ctx, chainErr); handlerErr != nil {
+				_ = ctx.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		return nil
+	}
+}
+
+// Logger returns the request logger set by RequestLogger, or logger.Default().
+func Logger(ctx *fiber.Ctx) *slog.Logger {
+	return logger.FromContext(ctx.UserContext())
+}
+
+// CurrentRequestID returns the request ID set by RequestLogger.
+func CurrentRequestID(ctx *fiber.Ctx) string {
+	requestId, _ := ctx.Locals(RequestIDKey).(string)
+	return requestId
+}
+
+// addLogFields adds fields to the request logger, used once the user, store or tenant is known.
+func addLogFields(ctx *fiber.Ctx, args ...any) {
+	ctx.SetUserContext(logger.With(ctx.UserContext(), args...))
+}
+
+func logAccess(ctx *fiber.Ctx, start time.Time) {
+	status := ctx.Response().StatusCode()
+
+	level := slog.LevelInfo
+	switch {
+	case status >= fiber.StatusInternalServerError:
+		level = slog.LevelError
+	case status >= fiber.StatusBadRequest:
+		level = slog.LevelWarn
+	}
+
+	Logger(ctx).Log(
+		ctx.UserContext(),
+		level,
+		"request completed",
+		"status", status,
+		"latency_ms", float64(time.Since(start).Microseconds())/1000,
+		"bytes", len(ctx.Response().Body()),
+		"ip", ctx.IP(),
+		"user_agent", ctx.Get(fiber.HeaderUserAgent),
+	)
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/susatyo441/go-ta-utils/policy"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RoleResolver returns the roles of a user in a store.
+type RoleResolver func(ctx *fiber.Ctx, userId primitive.ObjectID, storeId primitive.ObjectID) ([]policy.Role, error)
+
+type PermissionOptions struct {
+	// Defaults to policy.DefaultPolicy().
+	Policy *policy.Policy
+	// Defaults to the roles of the token (Claims.Roles), which are the roles in the token's store.
+	Roles RoleResolver
+}
+
+func determinePermissionOptions(opts ...PermissionOptions) PermissionOptions {
+	actualOpts := PermissionOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.Policy == nil {
+		actualOpts.Policy = policy.DefaultPolicy()
+	}
+	if actualOpts.Roles == nil {
+		actualOpts.Roles = claimsRoles
+	}
+
+	return actualOpts
+}
+
+// RequirePermission allows the request only when the user's roles in the current store (StoreKey)
+// grant permission. Must run after ValidateJWT. The resolved roles are saved in RolesKey.
+// Requests authenticated by ValidateAPIKey are checked against the key scopes instead,
+// saved in RolesKey as policy.ScopeRoles so use cases check them with the same Policy.
+//
+// EXAMPLE:
+//
+//	app.Patch("/product/:id", middleware.ValidateJWT(), middleware.RequirePermission("product:update"), handler)
+//
+//	// Roles from the database instead of the token
+//	middleware.RequirePermission(policy.PermissionTransactionDelete, middleware.PermissionOptions{
+//		Roles: func(ctx *fiber.Ctx, userId, storeId primitive.ObjectID) ([]policy.Role, error) {
+//			user, err := userService.FindOne(ctx.Context(), bson.M{"_id": userId})
+//			if err != nil {
+//				return nil, err
+//			}
+//			return policy.RolesOf(*user, storeId), nil
+//		},
+//	})
+func RequirePermission(permission policy.Permission, opts ...PermissionOptions) fiber.Handler {
+	options := determinePermissionOptions(opts...)
+
+	return func(ctx *fiber.Ctx) error {
+		userId, ok := CurrentUser(ctx)
+		if !ok {
+			return fiber.NewError(fiber.StatusUnauthorized, "Unauthorized")
+		}
+
+		storeId, ok := CurrentStore(ctx)
+		if !ok {
+			return fiber.NewError(fiber.StatusForbidden, "No store selected")
+		}
+
+		// API keys are limited to their scopes, whatever the roles of their user.
+		if apiKey, ok := CurrentAPIKey(ctx); ok {
+			roles := policy.ScopeRoles(apiKey.Scopes)
+			if !options.Policy.Can(roles, permission) {
+				return fiber.NewError(fiber.StatusForbidden, "API key is not allowed to "+string(permission))
+			}
+
+			ctx.Locals(RolesKey, roles)
+			return ctx.Next()
+		}
+
+		roles, err := options.Roles(ctx, userId, storeId)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+
+		if err := options.Policy.Authorize(roles, permission); err != nil {
+			return fiber.NewError(fiber.StatusForbidden, err.Message)
+		}
+
+		ctx.Locals(RolesKey, roles)
+		return ctx.Next()
+	}
+}
+
+// CurrentRoles returns the roles resolved by RequirePermission,
+// to be passed to policy.Policy in use cases.
+func CurrentRoles(ctx *fiber.Ctx) []policy.Role {
+	roles, _ := ctx.Locals(RolesKey).([]policy.Role)
+	return roles
+}
+
+func claimsRoles(ctx *fiber.Ctx, _ primitive.ObjectID, _ primitive.ObjectID) ([]policy.Role, error) {
+	claims, ok := CurrentClaims(ctx)
+	if !ok {
+		return nil, nil
+	}
+
+	roles := make([]policy.Role, 0, len(claims.Roles))
+	for _, role := range claims.Roles {
+		roles = append(roles, policy.Role(role))
+	}
+
+	return roles, nil
+}
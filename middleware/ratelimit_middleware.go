@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/susatyo441/go-ta-utils/entity"
+	"github.com/susatyo441/go-ta-utils/ratelimit"
+)
+
+// RateLimitKey returns the identity a request is counted for.
+type RateLimitKey func(ctx *fiber.Ctx) string
+
+// RateLimitByIP counts requests per client IP.
+func RateLimitByIP(ctx *fiber.Ctx) string {
+	return "ip:" + ctx.IP()
+}
+
+// RateLimitByUser counts requests per authenticated user (UserKey), per IP when anonymous.
+func RateLimitByUser(ctx *fiber.Ctx) string {
+	if userId, ok := CurrentUser(ctx); ok {
+		return "user:" + userId.Hex()
+	}
+	return RateLimitByIP(ctx)
+}
+
+// RateLimitByStore counts requests per store (StoreKey), falling back to RateLimitByUser.
+func RateLimitByStore(ctx *fiber.Ctx) string {
+	if storeId, ok := CurrentStore(ctx); ok {
+		return "store:" + storeId.Hex()
+	}
+	return RateLimitByUser(ctx)
+}
+
+// RateLimitByAPIKey counts requests per API key (APIKeyKey), falling back to RateLimitByUser.
+func RateLimitByAPIKey(ctx *fiber.Ctx) string {
+	if apiKey, ok := CurrentAPIKey(ctx); ok {
+		return "apikey:" + apiKey.ID.Hex()
+	}
+	return RateLimitByUser(ctx)
+}
+
+type RateLimitOptions struct {
+	// Counters are separate per name, so limits of different routes do not add up. Defaults to "default".
+	Name string
+	// Defaults to 100 requests per minute, sliding window.
+	Limit ratelimit.Limit
+	// Defaults to ratelimit.NewMemoryStore(). Use ratelimit.NewMongoStore() with several instances.
+	Store ratelimit.Store
+	// Defaults to RateLimitByUser.
+	Key RateLimitKey
+	// Reject requests when the store fails. By default they are let through.
+	FailClosed bool
+}
+
+func determineRateLimitOptions(opts ...RateLimitOptions) RateLimitOptions {
+	actualOpts := RateLimitOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.Name == "" {
+		actualOpts.Name = "default"
+	}
+	if actualOpts.Limit.Requests == 0 {
+		actualOpts.Limit.Requests = 100
+	}
+	if actualOpts.Limit.Window == 0 {
+		actualOpts.Limit.Window = time.Minute
+	}
+	if actualOpts.Store == nil {
+		actualOpts.Store = ratelimit.NewMemoryStore()
+	}
+	if actualOpts.Key == nil {
+		actualOpts.Key = RateLimitByUser
+	}
+
+	return actualOpts
+}
+
+// Middleware to limit the request rate per key (IP, user, store or API key).
+//
+// Sets the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers,
+// and responds 429 with Retry-After when the limit is reached.
+// Place it after ValidateJWT / ValidateAPIKey when keyed by user, store or API key.
+//
+// EXAMPLE:
+//
+//	app.Post("/auth/login", middleware.RateLimit(middleware.RateLimitOptions{
+//		Name:  "login",
+//		Limit: ratelimit.Limit{Requests: 5, Window: time.Minute},
+//		Key:   middleware.RateLimitByIP,
+//	}), handler)
+//
+//	app.Post("/product/photo", middleware.ValidateJWT(), middleware.RateLimit(middleware.RateLimitOptions{
+//		Name:  "upload",
+//		Limit: ratelimit.Limit{Requests: 30, Window: time.Minute, Algorithm: ratelimit.AlgorithmTokenBucket, Burst: 10},
+//		Store: ratelimit.NewMongoStore(),
+//		Key:   middleware.RateLimitByStore,
+//	}), handler)
+func RateLimit(opts ...RateLimitOptions) fiber.Handler {
+	actualOpts := determineRateLimitOptions(opts...)
+	policy := strconv.Itoa(actualOpts.Limit.Requests) + ";w=" + strconv.Itoa(int(actualOpts.Limit.Window.Seconds()))
+	if actualOpts.Limit.Algorithm == ratelimit.AlgorithmTokenBucket && actualOpts.Limit.Burst > 0 {
+		policy += ";burst=" + strconv.Itoa(actualOpts.Limit.Burst)
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		key := actualOpts.Name + ":" + actualOpts.Key(ctx)
+
+		result, err := actualOpts.Store.Allow(ctx.UserContext(), key, actualOpts.Limit, time.Now())
+		if err != nil {
+			if actualOpts.FailClosed {
+				return entity.InternalServerError("Error checking rate limit: " + err.Error()).SendResponse(ctx)
+			}
+			Logger(ctx).Warn("Rate limit check failed, request allowed", "error", err)
+			return ctx.Next()
+		}
+
+		ctx.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		ctx.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		ctx.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		ctx.Set("RateLimit-Policy", policy)
+
+		if !result.Allowed {
+			retryAfter := ceilSeconds(result.RetryAfter)
+			ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			return entity.TooManyRequests("Too many requests, retry in " + strconv.Itoa(retryAfter) + " seconds").SendResponse(ctx)
+		}
+
+		return ctx.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/susatyo441/go-ta-utils/storage"
+)
+
+// Saves each variant of the image of attribute in storage.Default() under "<folderName>/"
+// (storage.DefaultVariants when none are given), named by a new ULID, and their paths by variant name
+// (map[string]string of "/<folderName>/<ulid>.<jpg|png>") in ctx.Locals(ContextKey(attribute)).
+// Transparent images are saved as PNG unless the variants choose another storage.Format.
+// To share identical images within a store, use functions.SaveImageVariants.
+//
+// EXAMPLE:
+//
+//	app.Put("/profile", middleware.SaveImageVariantsMiddleware("profile", "picture"), handler)
+//	// in handler
+//	paths, _ := ctx.Locals(middleware.ContextKey("picture")).(map[string]string)
+//	user.ProfilePictureSmall = functions.MakePointer(paths["small"])
+func SaveImageVariantsMiddleware(folderName string, attribute string, variants ...storage.Variant) fiber.Handler {
+	if len(variants) == 0 {
+		variants = storage.DefaultVariants
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		// Get the uploaded file, FormFile attribute
+		fileHeader, err := ctx.FormFile(attribute)
+		if err != nil {
+			return ctx.Next()
+		}
+
+		// Decode the image, within the upload limits
+		img, mimeType, err := storage.DecodeUpload(fileHeader)
+		if err != nil {
+			return fiber.NewError(storage.DecodeErrorStatus(err), "Invalid image: "+err.Error())
+		}
+
+		keys, err := storage.SaveVariants(
+			ctx.UserContext(),
+			storage.Default(),
+			folderName,
+			img,
+			variants,
+			storage.SaveImageOptions{SourceType: mimeType, Naming: storage.NamingULID},
+		)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Error saving resized image: "+err.Error())
+		}
+
+		paths := make(map[string]string, len(keys))
+		for name, key := range keys {
+			paths[name] = "/" + key
+		}
+
+		ctx.Locals(ContextKey(attribute), paths)
+		return ctx.Next()
+	}
+}
@@ -1,71 +1,94 @@
 package middleware
 
 import (
-	"fmt"
-	"image"
-	"os"
-	"path/filepath"
-	"strconv"
-	"time"
+	"path"
 
-	"github.com/disintegration/imaging"
 	"github.com/gofiber/fiber/v2"
+	"github.com/susatyo441/go-ta-utils/storage"
 )
 
-func SaveSingleImageMiddleware(folderName string, attribute string) fiber.Handler {
-	return func(ctx *fiber.Ctx) error {
-
-		// Get the uploaded file, FormFile attribute
-		fileHeader, err := ctx.FormFile(attribute)
-		if err != nil {
-			return ctx.Next()
-		}
+// Saves the image of attribute in storage.Default() under "<folderName>/",
+// and its path ("/<folderName>/<name>.<jpg|png>") in ctx.Locals(ContextKey(attribute)).
+// Transparent images are saved as PNG unless opts choose another storage.Format.
+//
+// Images are named by a new ULID unless opts choose storage.NamingContentHash, which shares one file
+// between identical images (see storage.SaveImage): only choose it when folderName belongs to a single
+// tenant, or use SaveStoreImageMiddleware.
+func SaveSingleImageMiddleware(folderName string, attribute string, opts ...storage.SaveImageOptions) fiber.Handler {
+	saveOpts := storage.SaveImageOptions{}
+	if len(opts) > 0 {
+		saveOpts = opts[0]
+	}
+	if saveOpts.Naming == "" {
+		saveOpts.Naming = storage.NamingULID
+	}
 
-		file, err := fileHeader.Open()
-		if err != nil {
-			return ctx.Next()
+	return func(ctx *fiber.Ctx) error {
+		savedKey, err := saveUploadedImage(ctx, folderName, attribute, saveOpts)
+		if err != nil || savedKey == "" {
+			return err
 		}
 
-		defer file.Close()
+		ctx.Locals(ContextKey(attribute), "/"+savedKey)
+		return ctx.Next()
+	}
+}
 
-		// Decode the image
-		img, _, err := image.Decode(file)
-		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "Error decoding image: "+err.Error())
+// Saves the image of attribute in storage.Default() under "<storeId>/<folderName>/", with the store of the token
+// (see ValidateJWT), named by its content hash so identical images of a store share one file (see storage.SaveImage).
+// Its path, relative to the store like functions.SaveMultiImages ("/<folderName>/<sha256>.<jpg|png>"),
+// is saved in ctx.Locals(ContextKey(attribute)), ready for ProductPhotoUseCase.AddProductPhotos.
+// Requests without a store are rejected with 403.
+//
+// EXAMPLE:
+//
+//	app.Post("/products/:id/photos", middleware.ValidateJWT(), middleware.SaveStoreImageMiddleware("product", "photo"), handler)
+func SaveStoreImageMiddleware(folderName string, attribute string, opts ...storage.SaveImageOptions) fiber.Handler {
+	saveOpts := storage.SaveImageOptions{}
+	if len(opts) > 0 {
+		saveOpts = opts[0]
+	}
 
+	return func(ctx *fiber.Ctx) error {
+		storeId, ok := CurrentStore(ctx)
+		if !ok {
+			return fiber.NewError(fiber.StatusForbidden, "Store is required to upload images")
 		}
 
-		absPath, _ := filepath.Abs(fmt.Sprintf("../acts-files/%s", folderName))
-		// Save the resized image to disk
-		outputFilePath := filepath.Join(
-			absPath,
-			fmt.Sprintf("%s.jpg", strconv.FormatInt(time.Now().Unix(), 10)),
-		)
-		relativePath := fmt.Sprintf(
-			"/%s/%s",
-			folderName,
-			fmt.Sprintf("%s.jpg", strconv.FormatInt(time.Now().Unix(), 10)),
-		)
-
-		// Create the directory if it doesn't exist
-		if err := os.MkdirAll(absPath, os.ModePerm); err != nil {
-			return fiber.NewError(
-				fiber.StatusInternalServerError,
-				"Error creating directory: "+err.Error(),
-			)
+		savedKey, err := saveUploadedImage(ctx, path.Join(storeId.Hex(), folderName), attribute, saveOpts)
+		if err != nil || savedKey == "" {
+			return err
 		}
 
-		saveErr := imaging.Save(img, outputFilePath)
-		if saveErr != nil {
-			return fiber.NewError(
-				fiber.StatusInternalServerError,
-				"Error saving resized image: "+saveErr.Error(),
-			)
-		}
+		ctx.Locals(ContextKey(attribute), "/"+path.Join(folderName, path.Base(savedKey)))
+		return ctx.Next()
+	}
+}
 
-		key := ContextKey(attribute)
+// saveUploadedImage saves the image of attribute under dir, returning its key.
+// Without an upload it continues to the next handler and returns an empty key.
+func saveUploadedImage(ctx *fiber.Ctx, dir string, attribute string, saveOpts storage.SaveImageOptions) (string, error) {
+	// Get the uploaded file, FormFile attribute
+	fileHeader, err := ctx.FormFile(attribute)
+	if err != nil {
+		return "", ctx.Next()
+	}
 
-		ctx.Locals(key, relativePath)
-		return ctx.Next()
+	// Decode the image, within the upload limits
+	img, mimeType, err := storage.DecodeUpload(fileHeader)
+	if err != nil {
+		return "", fiber.NewError(storage.DecodeErrorStatus(err), "Invalid image: "+err.Error())
+	}
+
+	// Save the image to the storage backend
+	saveOpts.SourceType = mimeType
+	savedKey, err := storage.SaveImage(ctx.UserContext(), storage.Default(), dir, img, saveOpts)
+	if err != nil {
+		return "", fiber.NewError(
+			fiber.StatusInternalServerError,
+			"Error saving resized image: "+err.Error(),
+		)
 	}
+
+	return savedKey, nil
 }
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SessionChecker tells whether a session is still active (not revoked nor expired).
+// Implemented by service.ISessionUseCase.
+type SessionChecker interface {
+	IsSessionActive(ctx context.Context, sessionId primitive.ObjectID) (bool, error)
+}
+
+type sessionCacheEntry struct {
+	active    bool
+	expiresAt time.Time
+}
+
+// CachedSessionChecker caches the answers of a SessionChecker in process,
+// so ValidateJWT does not query Mongo on every request.
+//
+// Revoked sessions never become active again, so they are cached until evicted.
+// Active sessions are re-checked after the TTL, which bounds how long a revoked
+// session stays usable on other instances.
+type CachedSessionChecker struct {
+	checker    SessionChecker
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[primitive.ObjectID]sessionCacheEntry
+}
+
+// NewCachedSessionChecker wraps checker with a cache. A zero ttl defaults to 30 seconds.
+//
+// EXAMPLE:
+//
+//	sessions := middleware.NewCachedSessionChecker(service.NewCompanySessionUseCase(companyCode), 30*time.Second)
+//	app.Use(middleware.ValidateJWT(middleware.JWTOptions{Sessions: sessions}))
+//
+//	// On logout, revoke then drop the local cache entry
+//	sessionUseCase.RevokeSession(ctx, sessionId, service.SessionRevokedLogout)
+//	sessions.Invalidate(sessionId)
+func NewCachedSessionChecker(checker SessionChecker, ttl time.Duration) *CachedSessionChecker {
+	if ttl == 0 {
+		ttl = 30 * time.Second
+	}
+
+	return &CachedSessionChecker{
+		checker:    checker,
+		ttl:        ttl,
+		maxEntries: 10000,
+		entries:    map[primitive.ObjectID]sessionCacheEntry{},
+	}
+}
+
+func (c *CachedSessionChecker) IsSessionActive(ctx context.Context, sessionId primitive.ObjectID) (bool, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, exist := c.entries[sessionId]
+	c.mu.Unlock()
+
+	if exist && (!entry.active || now.Before(entry.expiresAt)) {
+		return entry.active, nil
+	}
+
+	active, err := c.checker.IsSessionActive(ctx, sessionId)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+	c.entries[sessionId] = sessionCacheEntry{active: active, expiresAt: now.Add(c.ttl)}
+
+	return active, nil
+}
+
+// Invalidate drops the cached answer for a session, e.g. right after revoking it.
+func (c *CachedSessionChecker) Invalidate(sessionId primitive.ObjectID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, sessionId)
+}
+
+// evict drops expired entries, or everything if none expired. Must hold c.mu.
+func (c *CachedSessionChecker) evict(now time.Time) {
+	for id, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, id)
+		}
+	}
+
+	if len(c.entries) >= c.maxEntries {
+		c.entries = map[primitive.ObjectID]sessionCacheEntry{}
+	}
+}
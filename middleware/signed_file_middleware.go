@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/susatyo441/go-ta-utils/storage"
+)
+
+type SignedFileOptions struct {
+	// Defaults to storage.Default().
+	Backend storage.Backend
+}
+
+// Serves the files of the signed URLs created by signer, to mount with a wildcard on the path of its base URL.
+// Rejects missing, invalid and expired signatures with 403. Supports conditional (ETag) and range requests.
+//
+// EXAMPLE:
+//
+//	signer, _ := storage.NewURLSigner([]byte(os.Getenv("FILE_URL_KEY")), "https://api.example.com/files")
+//	app.Get("/files/*", middleware.ServeSignedFiles(signer))
+func ServeSignedFiles(signer *storage.URLSigner, opts ...SignedFileOptions) fiber.Handler {
+	actualOpts := SignedFileOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		backend := actualOpts.Backend
+		if backend == nil {
+			backend = storage.Default()
+		}
+
+		key, err := url.PathUnescape(ctx.Params("*"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusForbidden, "Invalid signature")
+		}
+
+		now := time.Now()
+		expires := ctx.Query("expires")
+		err = signer.Verify(key, expires, ctx.Query("signature"), now)
+		if errors.Is(err, storage.ErrExpiredSignature) {
+			return fiber.NewError(fiber.StatusForbidden, "Link expired")
+		}
+		if err != nil {
+			return fiber.NewError(fiber.StatusForbidden, "Invalid signature")
+		}
+
+		info, err := backend.Stat(ctx.UserContext(), key)
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			return fiber.NewError(fiber.StatusNotFound, "File not found")
+		}
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Error reading file: "+err.Error())
+		}
+
+		// Cacheable by the browser until the link expires, never by shared caches.
+		expiresAt, _ := strconv.ParseInt(expires, 10, 64)
+		ctx.Set(fiber.HeaderCacheControl, fmt.Sprintf("private, max-age=%d", max(expiresAt-now.Unix(), 0)))
+		ctx.Set(fiber.HeaderContentType, info.ContentType)
+		ctx.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+		ctx.Set(fiber.HeaderAcceptRanges, "bytes")
+		if info.ETag != "" {
+			ctx.Set(fiber.HeaderETag, info.ETag)
+		}
+		if !info.ModTime.IsZero() {
+			ctx.Set(fiber.HeaderLastModified, info.ModTime.UTC().Format(http.TimeFormat))
+		}
+
+		if info.ETag != "" && etagMatches(ctx.Get(fiber.HeaderIfNoneMatch), info.ETag) {
+			return ctx.SendStatus(fiber.StatusNotModified)
+		}
+
+		start, length := int64(0), info.Size
+		status := fiber.StatusOK
+		rangeHeader := ctx.Get(fiber.HeaderRange)
+		ifRange := ctx.Get(fiber.HeaderIfRange)
+		if rangeHeader != "" && (ifRange == "" || ifRange == info.ETag) {
+			rangeStart, rangeEnd, satisfiable, ok := parseByteRange(rangeHeader, info.Size)
+			if ok && !satisfiable {
+				ctx.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", info.Size))
+				return ctx.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+			}
+			if ok {
+				start, length = rangeStart, rangeEnd-rangeStart+1
+				status = fiber.StatusPartialContent
+				ctx.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", rangeStart, rangeEnd, info.Size))
+			}
+		}
+
+		ctx.Status(status)
+		if ctx.Method() == fiber.MethodHead {
+			ctx.Response().Header.SetContentLength(int(length))
+			ctx.Response().SkipBody = true
+			return nil
+		}
+
+		reader, _, err := backend.Get(ctx.UserContext(), key)
+		if errors.Is(err, storage.ErrNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "File not found")
+		}
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Error reading file: "+err.Error())
+		}
+
+		if start > 0 {
+			if seeker, ok := reader.(io.Seeker); ok {
+				_, err = seeker.Seek(start, io.SeekStart)
+			} else {
+				_, err = io.CopyN(io.Discard, reader, start)
+			}
+			if err != nil {
+				reader.Close()
+				return fiber.NewError(fiber.StatusInternalServerError, "Error reading file: "+err.Error())
+			}
+		}
+
+		// Closed by fasthttp once sent.
+		stream := struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(reader, length), reader}
+
+		return ctx.SendStream(stream, int(length))
+	}
+}
+
+// parseByteRange parses a single "bytes=" range of a file of size bytes, returning the inclusive bounds.
+// ok is false when the header is not a single byte range, which is then ignored.
+func parseByteRange(header string, size int64) (start int64, end int64, satisfiable bool, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, false
+	}
+
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, false
+	}
+
+	if first == "" {
+		// Suffix range: the last n bytes.
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false, false
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false, true
+		}
+		return max(size-n, 0), size - 1, true, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, false
+	}
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, false
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, false, true
+	}
+
+	return start, end, true, true
+}
+
+// etagMatches reports whether an If-None-Match header matches etag.
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
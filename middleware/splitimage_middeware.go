@@ -1,18 +1,23 @@
 package middleware
 
 import (
-	"fmt"
-	"image"
-	"os"
-	"path/filepath"
-	"strconv"
-	"time"
+	"path"
 
-	"github.com/disintegration/imaging"
 	"github.com/gofiber/fiber/v2"
+	"github.com/susatyo441/go-ta-utils/storage"
 )
 
-// Deprecated: use middleware.SaveSingleImageMiddleware (for single file) since admin console doesnt need splitted image
+// Sizes saved by SplitImageMiddleware.
+var splitImageVariants = []storage.Variant{
+	{Name: "small", Width: 300, Height: 300},
+	{Name: "medium", Width: 600, Height: 600},
+	{Name: "large", Width: 1200, Height: 1200},
+}
+
+// Saves the resized images in storage.Default() and their paths in ctx.Locals(ContextKey(size)).
+//
+// Deprecated: use middleware.SaveSingleImageMiddleware (for single file) since admin console doesnt need splitted image,
+// or middleware.SaveImageVariantsMiddleware
 func SplitImageMiddleware(folderName string, attribute string) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 
@@ -22,63 +27,35 @@ func SplitImageMiddleware(folderName string, attribute string) fiber.Handler {
 			return fiber.NewError(fiber.StatusBadRequest, "Error retrieving the file: "+err.Error())
 		}
 
-		file, err := fileHeader.Open()
-		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "Error retrieving the file: "+err.Error())
-		}
-
-		defer file.Close()
-
-		// Decode the image
-		img, _, err := image.Decode(file)
+		// Decode the image, within the upload limits
+		img, mimeType, err := storage.DecodeUpload(fileHeader)
 		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "Error decoding image: "+err.Error())
-
+			return fiber.NewError(storage.DecodeErrorStatus(err), "Invalid image: "+err.Error())
 		}
 
-		// Define sizes for resizing the image
-		sizes := []struct {
-			name          string
-			width, height int
-		}{
-			{"small", 300, 300},
-			{"medium", 600, 600},
-			{"large", 1200, 1200},
+		companyCode, ok := CurrentCompanyCode(ctx)
+		if !ok {
+			return fiber.NewError(fiber.StatusInternalServerError, "Company code not resolved, use middleware.ResolveTenant")
 		}
 
-		// Process and save resized images
-		for _, size := range sizes {
-			// Resize the image to the specified dimensions
-			resizedImg := imaging.Resize(img, size.width, size.height, imaging.Lanczos)
-
-			companyCode := ctx.Locals(ContextKey("companyCode")).(string)
-
-			absPath, _ := filepath.Abs(fmt.Sprintf("../acts-files/%s/%s", companyCode, folderName))
-			// Save the resized image to disk
-			outputFilePath := filepath.Join(
-				absPath,
-				fmt.Sprintf("%s.jpg", strconv.FormatInt(time.Now().Unix(), 10)+"-"+size.name),
+		// Resize and save, preserving the aspect ratio
+		keys, err := storage.SaveVariants(
+			ctx.UserContext(),
+			storage.Default(),
+			path.Join(companyCode, folderName),
+			img,
+			splitImageVariants,
+			storage.SaveImageOptions{SourceType: mimeType},
+		)
+		if err != nil {
+			return fiber.NewError(
+				fiber.StatusInternalServerError,
+				"Error saving resized image: "+err.Error(),
 			)
+		}
 
-			// Create the directory if it doesn't exist
-			if err := os.MkdirAll(absPath, os.ModePerm); err != nil {
-				return fiber.NewError(
-					fiber.StatusInternalServerError,
-					"Error creating directory: "+err.Error(),
-				)
-			}
-
-			err := imaging.Save(resizedImg, outputFilePath)
-			if err != nil {
-				return fiber.NewError(
-					fiber.StatusInternalServerError,
-					"Error saving resized image: "+err.Error(),
-				)
-			}
-
-			key := ContextKey(size.name)
-
-			ctx.Locals(key, outputFilePath)
+		for name, savedKey := range keys {
+			ctx.Locals(ContextKey(name), "/"+savedKey)
 		}
 
 		return ctx.Next()
@@ -0,0 +1,241 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/susatyo441/go-ta-utils/model"
+)
+
+// Tenant codes end up in database names, so only a safe charset is accepted.
+var tenantCodePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,62}$`)
+
+// TenantRegistry finds a tenant by code or subdomain, returning nil when unknown.
+// Implemented by service.ITenantUseCase, StaticTenantRegistry and CachedTenantRegistry.
+type TenantRegistry interface {
+	FindTenant(ctx context.Context, codeOrSubdomain string) (*model.Tenant, error)
+}
+
+// TenantSource extracts a tenant code from a request, empty when absent.
+type TenantSource func(ctx *fiber.Ctx) string
+
+// TenantFromClaim reads the "company" claim. Must run after ValidateJWT.
+func TenantFromClaim() TenantSource {
+	return func(ctx *fiber.Ctx) string {
+		claims, ok := CurrentClaims(ctx)
+		if !ok {
+			return ""
+		}
+		return claims.CompanyCode
+	}
+}
+
+// TenantFromHeader reads a header, e.g. "X-Company-Code".
+func TenantFromHeader(header string) TenantSource {
+	return func(ctx *fiber.Ctx) string {
+		return strings.TrimSpace(ctx.Get(header))
+	}
+}
+
+// TenantFromSubdomain reads the subdomain directly under baseDomain,
+// e.g. "acme" for "acme.example.com" with baseDomain "example.com".
+func TenantFromSubdomain(baseDomain string) TenantSource {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+
+	return func(ctx *fiber.Ctx) string {
+		host := strings.ToLower(ctx.Hostname())
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		subdomain, found := strings.CutSuffix(host, suffix)
+		if !found || strings.Contains(subdomain, ".") {
+			return ""
+		}
+		return subdomain
+	}
+}
+
+type TenantOptions struct {
+	// Where to read the tenant code or subdomain from. Every source that yields one must resolve to the same tenant,
+	// so a token of one tenant cannot be used on another tenant's subdomain or header.
+	// Defaults to TenantFromClaim, TenantFromHeader("X-Company-Code") and,
+	// when the TENANT_BASE_DOMAIN env var is set, TenantFromSubdomain.
+	Sources []TenantSource
+}
+
+func determineTenantOptions(opts ...TenantOptions) TenantOptions {
+	actualOpts := TenantOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if len(actualOpts.Sources) == 0 {
+		actualOpts.Sources = []TenantSource{TenantFromClaim(), TenantFromHeader("X-Company-Code")}
+		if baseDomain := os.Getenv("TENANT_BASE_DOMAIN"); baseDomain != "" {
+			actualOpts.Sources = append(actualOpts.Sources, TenantFromSubdomain(baseDomain))
+		}
+	}
+
+	return actualOpts
+}
+
+// Middleware to resolve the tenant (company or partner) of the request.
+//
+// Validates the code against registry and sets CompanyCodeKey (string) and TenantKey (*model.Tenant),
+// read them with CurrentCompanyCode and CurrentTenant.
+//
+// EXAMPLE:
+//
+//	tenants := middleware.NewCachedTenantRegistry(service.NewAdminTenantUseCase(), time.Minute)
+//	app.Use(middleware.ValidateJWT(), middleware.ResolveTenant(tenants))
+//
+//	// in the handler
+//	tenant, _ := middleware.CurrentTenant(ctx)
+//	productService := service.NewTenantService[model.Product](tenant, db.ProductModelName)
+func ResolveTenant(registry TenantRegistry, opts ...TenantOptions) fiber.Handler {
+	actualOpts := determineTenantOptions(opts...)
+
+	return func(ctx *fiber.Ctx) error {
+		var tenant *model.Tenant
+		for _, source := range actualOpts.Sources {
+			code := source(ctx)
+			if code == "" {
+				continue
+			}
+			if !tenantCodePattern.MatchString(code) {
+				return fiber.NewError(fiber.StatusBadRequest, "Invalid tenant")
+			}
+
+			found, err := registry.FindTenant(ctx.UserContext(), code)
+			if err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, "Error resolving tenant: "+err.Error())
+			}
+			if found == nil {
+				return fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+			}
+			if tenant != nil && found.Code != tenant.Code {
+				return fiber.NewError(fiber.StatusForbidden, "Tenant mismatch")
+			}
+			tenant = found
+		}
+
+		if tenant == nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Missing tenant")
+		}
+		if !tenant.IsActive {
+			return fiber.NewError(fiber.StatusForbidden, "Tenant is inactive")
+		}
+
+		ctx.Locals(CompanyCodeKey, tenant.Code)
+		ctx.Locals(TenantKey, tenant)
+		addLogFields(ctx, "company", tenant.Code)
+		return ctx.Next()
+	}
+}
+
+// CurrentTenant returns the tenant resolved by ResolveTenant.
+func CurrentTenant(ctx *fiber.Ctx) (*model.Tenant, bool) {
+	tenant, ok := ctx.Locals(TenantKey).(*model.Tenant)
+	return tenant, ok && tenant != nil
+}
+
+// CurrentCompanyCode returns the tenant code resolved by ResolveTenant.
+func CurrentCompanyCode(ctx *fiber.Ctx) (string, bool) {
+	code, ok := ctx.Locals(CompanyCodeKey).(string)
+	return code, ok && code != ""
+}
+
+// StaticTenantRegistry is an in-memory TenantRegistry, for single-tenant deployments and tests.
+type StaticTenantRegistry struct {
+	tenants map[string]*model.Tenant
+}
+
+// NewStaticTenantRegistry is a constructor to initialize StaticTenantRegistry
+func NewStaticTenantRegistry(tenants ...model.Tenant) *StaticTenantRegistry {
+	registry := &StaticTenantRegistry{tenants: map[string]*model.Tenant{}}
+	for i := range tenants {
+		tenant := tenants[i]
+		registry.tenants[tenant.Code] = &tenant
+		for _, subdomain := range tenant.Subdomains {
+			registry.tenants[subdomain] = &tenant
+		}
+	}
+
+	return registry
+}
+
+func (r *StaticTenantRegistry) FindTenant(_ context.Context, codeOrSubdomain string) (*model.Tenant, error) {
+	return r.tenants[codeOrSubdomain], nil
+}
+
+type tenantCacheEntry struct {
+	tenant    *model.Tenant
+	expiresAt time.Time
+}
+
+// CachedTenantRegistry caches the answers of a TenantRegistry in process,
+// so ResolveTenant does not query Mongo on every request. Unknown codes are cached too.
+type CachedTenantRegistry struct {
+	registry   TenantRegistry
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]tenantCacheEntry
+}
+
+// NewCachedTenantRegistry wraps registry with a cache. A zero ttl defaults to 1 minute.
+func NewCachedTenantRegistry(registry TenantRegistry, ttl time.Duration) *CachedTenantRegistry {
+	if ttl == 0 {
+		ttl = time.Minute
+	}
+
+	return &CachedTenantRegistry{
+		registry:   registry,
+		ttl:        ttl,
+		maxEntries: 10000,
+		entries:    map[string]tenantCacheEntry{},
+	}
+}
+
+func (c *CachedTenantRegistry) FindTenant(ctx context.Context, codeOrSubdomain string) (*model.Tenant, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, exist := c.entries[codeOrSubdomain]
+	c.mu.Unlock()
+
+	if exist && now.Before(entry.expiresAt) {
+		return entry.tenant, nil
+	}
+
+	tenant, err := c.registry.FindTenant(ctx, codeOrSubdomain)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		c.entries = map[string]tenantCacheEntry{}
+	}
+	c.entries[codeOrSubdomain] = tenantCacheEntry{tenant: tenant, expiresAt: now.Add(c.ttl)}
+
+	return tenant, nil
+}
+
+// Invalidate drops the cached answer for a code or subdomain, e.g. after deactivating a tenant.
+func (c *CachedTenantRegistry) Invalidate(codeOrSubdomain string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, codeOrSubdomain)
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// IssueToken signs claims with the signing key of keys, so issued tokens are accepted by ValidateJWT.
+// A nil key set uses the JWT_KEY env var (HS256). "exp" is required, "iat" defaults to now.
+//
+// EXAMPLE:
+//
+//	token, err := middleware.IssueToken(keys, middleware.Claims{
+//		UserID:    user.ID,
+//		SessionID: session.ID,
+//		StoreID:   *user.Store.ID,
+//		Roles:     []string{"owner"},
+//		ExpiresAt: time.Now().Add(15 * time.Minute),
+//		Issuer:    "acts-auth",
+//		Audience:  []string{"acts-api"},
+//	})
+func IssueToken(keys *KeySet, claims Claims) (string, error) {
+	if keys == nil {
+		var err error
+		keys, err = NewHMACKeySet([]byte(os.Getenv("JWT_KEY")))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if claims.ExpiresAt.IsZero() {
+		return "", errors.New("token must have an expiry")
+	}
+	if claims.IssuedAt.IsZero() {
+		claims.IssuedAt = time.Now()
+	}
+
+	key, ok := keys.SigningKey()
+	if !ok {
+		return "", errors.New("no signing key available")
+	}
+
+	method := jwt.GetSigningMethod(key.Algorithm)
+	if method == nil {
+		return "", fmt.Errorf("unsupported signing algorithm %s", key.Algorithm)
+	}
+
+	token := jwt.NewWithClaims(method, jwt.MapClaims(claims.ToMap()))
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+
+	return token.SignedString(key.PrivateKey)
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type APIKey struct {
+	ID      primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	StoreID primitive.ObjectID `json:"storeId"       bson:"storeId"`
+	// User who created the key, requests made with the key act as this user.
+	UserID primitive.ObjectID `json:"userId"        bson:"userId"`
+	Name   string             `json:"name"          bson:"name"`
+	// Public part of the key, shown in lists to identify it, e.g. "ta_1a2b3c4d".
+	Prefix string `json:"prefix" bson:"prefix"`
+	// SHA-256 of the secret part, never the key itself.
+	KeyHash string `json:"-" bson:"keyHash"`
+	// Permissions granted to the key, see policy.Permission. Wildcards allowed.
+	Scopes     []string   `json:"scopes"     bson:"scopes"`
+	LastUsedAt *time.Time `json:"lastUsedAt" bson:"lastUsedAt"`
+	LastUsedIP *string    `json:"lastUsedIp" bson:"lastUsedIp"`
+	ExpiresAt  *time.Time `json:"expiresAt"  bson:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt"  bson:"revokedAt"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
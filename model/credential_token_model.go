@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	CredentialTokenActivation    = "activation"
+	CredentialTokenPasswordReset = "password reset"
+)
+
+// CredentialToken is a single-use activation or password reset token.
+type CredentialToken struct {
+	ID     primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	UserID primitive.ObjectID `json:"userId"        bson:"userId"`
+	// CredentialTokenActivation or CredentialTokenPasswordReset.
+	Purpose string `json:"purpose" bson:"purpose"`
+	// SHA-256 of the token, never the token itself.
+	TokenHash string     `json:"-"         bson:"tokenHash"`
+	ExpiresAt time.Time  `json:"expiresAt" bson:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt"    bson:"usedAt"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
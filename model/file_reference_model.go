@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// FileReference counts the documents referencing a stored file (product photos, store logos,
+// profile pictures), see storage.ReferenceCounter.
+type FileReference struct {
+	// Storage key of the file.
+	ID    string `json:"_id"   bson:"_id"`
+	Count int    `json:"count" bson:"count"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
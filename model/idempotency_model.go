@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+const (
+	IdempotencyProcessing = "processing"
+	IdempotencyCompleted  = "completed"
+)
+
+// IdempotencyRecord is the stored outcome of a request sent with an Idempotency-Key.
+type IdempotencyRecord struct {
+	// "<scope>:<key>", scope being the store (or user) of the request.
+	ID  string `json:"_id" bson:"_id"`
+	Key string `json:"key" bson:"key"`
+	// SHA-256 of the method, path and body, to detect a key reused for another request.
+	Fingerprint string `json:"fingerprint" bson:"fingerprint"`
+	// IdempotencyProcessing or IdempotencyCompleted.
+	Status   string               `json:"status"   bson:"status"`
+	Response *IdempotencyResponse `json:"response" bson:"response"`
+	// When the current attempt claimed the key, to detect crashed attempts.
+	LockedAt time.Time `json:"lockedAt" bson:"lockedAt"`
+	// Deleted by Mongo after this date.
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+type IdempotencyResponse struct {
+	StatusCode  int    `json:"statusCode"  bson:"statusCode"`
+	ContentType string `json:"contentType" bson:"contentType"`
+	Body        []byte `json:"body"        bson:"body"`
+}
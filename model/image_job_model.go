@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ImageJobPending    = "pending"
+	ImageJobProcessing = "processing"
+	ImageJobDone       = "done"
+	ImageJobFailed     = "failed"
+)
+
+// ImageJob generates the variants of an uploaded image in the background,
+// then replaces its placeholder path in the fields of the owning document.
+type ImageJob struct {
+	ID primitive.ObjectID `json:"_id" bson:"_id"`
+	// Owning document: database (admin database when empty), collection and id.
+	CompanyCode string             `json:"companyCode" bson:"companyCode"`
+	Collection  string             `json:"collection"  bson:"collection"`
+	DocumentID  primitive.ObjectID `json:"documentId"  bson:"documentId"`
+	// Field of the owning document set to the path of each variant, by variant name.
+	Fields map[string]string `json:"fields" bson:"fields"`
+	// Storage directory of the variants, and the prefix of their keys left out of the stored paths.
+	Dir        string `json:"dir"        bson:"dir"`
+	PathPrefix string `json:"pathPrefix" bson:"pathPrefix"`
+	// Storage key of the original upload, and its path stored in the fields until the job is done.
+	SourceKey   string            `json:"sourceKey"   bson:"sourceKey"`
+	SourceType  string            `json:"sourceType"  bson:"sourceType"`
+	Placeholder string            `json:"placeholder" bson:"placeholder"`
+	Variants    []ImageJobVariant `json:"variants"    bson:"variants"`
+
+	// ImageJobPending, ImageJobProcessing, ImageJobDone or ImageJobFailed.
+	Status   string `json:"status"   bson:"status"`
+	Attempts int    `json:"attempts" bson:"attempts"`
+	// Error of the last attempt.
+	Error string `json:"error" bson:"error"`
+	// Path of each variant, by variant name, once done.
+	Result map[string]string `json:"result" bson:"result"`
+	// A pending job is not run before this date, to space retries.
+	RunAfter time.Time `json:"runAfter" bson:"runAfter"`
+	// When the current attempt started, to detect crashed attempts.
+	LockedAt *time.Time `json:"lockedAt" bson:"lockedAt"`
+	// Deleted by Mongo after this date, set once done or failed.
+	ExpiresAt *time.Time `json:"expiresAt" bson:"expiresAt"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// ImageJobVariant mirrors storage.Variant.
+type ImageJobVariant struct {
+	Name    string `json:"name"    bson:"name"`
+	Width   int    `json:"width"   bson:"width"`
+	Height  int    `json:"height"  bson:"height"`
+	Mode    string `json:"mode"    bson:"mode"`
+	Format  string `json:"format"  bson:"format"`
+	Quality int    `json:"quality" bson:"quality"`
+}
@@ -23,6 +23,8 @@ type Product struct {
 	StoreID      primitive.ObjectID    `json:"storeId"        bson:"storeId"`
 	CapitalPrice *int                  `json:"capitalPrice" bson:"capitalPrice"`
 	Variants     []ProductVariantsAttr `json:"variants" bson:"variants"`
+	// Set while the photo gallery is being changed, see service.IProductPhotoUseCase.
+	GalleryLockedAt *time.Time `json:"-" bson:"galleryLockedAt,omitempty"`
 
 	CreatedAt time.Time `json:"createdAt"            bson:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"            bson:"updatedAt"`
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// RateLimitCounter is the state of one rate limit key, see ratelimit.MongoStore.
+// It has no CreatedAt/UpdatedAt on purpose: BaseService would rewrite its updates.
+type RateLimitCounter struct {
+	// "<key>" for token buckets, "<key>:<window start>" for sliding windows.
+	ID string `json:"_id" bson:"_id"`
+	// Requests counted in the window (sliding window).
+	Count int `json:"count" bson:"count"`
+	// Tokens left at At (token bucket).
+	Tokens float64   `json:"tokens" bson:"tokens"`
+	At     time.Time `json:"at"     bson:"at"`
+	// Deleted by Mongo after this date.
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
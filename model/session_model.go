@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Session struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"userId"        bson:"userId"`
+	StoreID   primitive.ObjectID `json:"storeId"       bson:"storeId"`
+	UserAgent *string            `json:"userAgent"     bson:"userAgent"`
+	IP        *string            `json:"ip"            bson:"ip"`
+	// SHA-256 of the current refresh token secret, never the token itself.
+	RefreshTokenHash string `json:"-" bson:"refreshTokenHash"`
+	// Hashes of the rotated refresh tokens, used to detect reuse.
+	PreviousTokenHashes []string   `json:"-"             bson:"previousTokenHashes"`
+	ExpiresAt           time.Time  `json:"expiresAt"     bson:"expiresAt"`
+	LastUsedAt          time.Time  `json:"lastUsedAt"    bson:"lastUsedAt"`
+	RevokedAt           *time.Time `json:"revokedAt"     bson:"revokedAt"`
+	RevokedReason       *string    `json:"revokedReason" bson:"revokedReason"`
+
+	CreatedAt time.Time `json:"createdAt"            bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"            bson:"updatedAt"`
+}
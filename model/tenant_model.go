@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// Company tenants use the "<code>_tagsamurai" database.
+	TenantKindCompany = "company"
+	// Partner tenants use the "<code>_admin_tagsamurai" database.
+	TenantKindPartner = "partner"
+)
+
+// Tenant is a company or partner, stored in the admin database.
+type Tenant struct {
+	ID   primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Code string             `json:"code"          bson:"code"`
+	Name string             `json:"name"          bson:"name"`
+	// TenantKindCompany or TenantKindPartner, defaults to TenantKindCompany when empty.
+	Kind string `json:"kind" bson:"kind"`
+	// Subdomains resolving to this tenant besides Code, e.g. "toko-maju".
+	Subdomains []string `json:"subdomains" bson:"subdomains"`
+	IsActive   bool     `json:"isActive"   bson:"isActive"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
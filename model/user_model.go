@@ -6,6 +6,12 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Role of a user in one store, see policy.Role.
+type UserStoreRole struct {
+	StoreID primitive.ObjectID `json:"storeId" bson:"storeId"`
+	Role    string             `json:"role"    bson:"role"`
+}
+
 type User struct {
 	ID                   primitive.ObjectID `json:"_id,omitempty"        bson:"_id,omitempty"`
 	Name                 string             `json:"name"            bson:"name"`
@@ -16,6 +22,7 @@ type User struct {
 	ProfilePictureSmall  *string            `json:"profilePictureSmall"  bson:"profilePictureSmall"`
 	ProfilePictureMedium *string            `json:"profilePictureMedium" bson:"profilePictureMedium"`
 	ProfilePictureBig    *string            `json:"profilePictureBig"    bson:"profilePictureBig"`
+	Roles                []UserStoreRole    `json:"roles"                bson:"roles"`
 
 	CreatedAt time.Time `json:"createdAt"            bson:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"            bson:"updatedAt"`
@@ -0,0 +1,238 @@
+package parser
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/susatyo441/go-ta-utils/entity"
+	"github.com/susatyo441/go-ta-utils/pipeline"
+)
+
+// filterField is an allowlisted field of a filter DTO.
+type filterField struct {
+	valueType reflect.Type
+	operators map[pipeline.FilterOperator]bool
+	sortable  bool
+}
+
+// ParseFilter parses the filter DSL from the query, validated against the allowlist declared on T.
+//
+// Query syntax:
+//   - filter[field][operator]=value, see pipeline.FilterOperator. filter[field]=value is a shorthand for eq.
+//   - in/nin take repeated keys or a comma-separated list (filter[category._id][in]=a,b).
+//   - sort=-createdAt,name sorts descending with a "-" prefix, ascending otherwise.
+//
+// Allowlist tags on T:
+//   - filter: the field path, as used in the query and in Mongo. Fields without it are not filterable.
+//   - ops: comma-separated allowed operators. Defaults to "eq".
+//   - sort: "true" to allow sorting on the field.
+//
+// Values are converted to the field type, like ParseQueryValues. Every unknown field,
+// disallowed operator or invalid value is returned at once as an *entity.HttpError (400).
+//
+// EXAMPLE:
+//
+//	type ProductFilter struct {
+//		Price     int                `filter:"price"        ops:"eq,gte,lte" sort:"true"`
+//		Category  primitive.ObjectID `filter:"category._id" ops:"eq,in,nin"`
+//		Name      string             `filter:"name"         ops:"eq,regex"   sort:"true"`
+//		CreatedAt time.Time          `filter:"createdAt"    ops:"gte,lte"    sort:"true"`
+//	}
+//
+//	filter, err := parser.ParseFilter[ProductFilter](values)
+//	products, err := productService.Find(ctx, filter.ToMatch())
+func ParseFilter[T any](query url.Values) (*pipeline.Filter, error) {
+	fields := filterFieldsOf(reflect.TypeFor[T]())
+	filter := &pipeline.Filter{
+		Conditions: []pipeline.FilterCondition{},
+		Sorts:      []pipeline.Sort{},
+	}
+	fieldErrors := []entity.FieldError{}
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path, operatorName, ok := parseFilterKey(key)
+		if !ok {
+			continue
+		}
+
+		raw := nonEmpty(query[key])
+		if len(raw) == 0 {
+			continue
+		}
+
+		fieldError := entity.FieldError{Field: key, Value: strings.Join(raw, ",")}
+
+		field, exist := fields[path]
+		if !exist {
+			fieldError.Message = "is not filterable"
+			fieldErrors = append(fieldErrors, fieldError)
+			continue
+		}
+
+		operator, exist := pipeline.GetFilterOperator(operatorName)
+		if !exist || !field.operators[operator] {
+			fieldError.Message = "operator " + operatorName + " is not allowed"
+			fieldErrors = append(fieldErrors, fieldError)
+			continue
+		}
+
+		value, err := parseFilterValue(field.valueType, operator, raw)
+		if err != nil {
+			fieldError.Message = err.Error()
+			fieldErrors = append(fieldErrors, fieldError)
+			continue
+		}
+
+		filter.Conditions = append(filter.Conditions, pipeline.FilterCondition{
+			Field:    path,
+			Operator: operator,
+			Value:    value,
+		})
+	}
+
+	for _, raw := range nonEmpty(query["sort"]) {
+		for _, item := range strings.Split(raw, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+
+			order := 1
+			path := item
+			if strings.HasPrefix(item, "-") {
+				order = -1
+				path = item[1:]
+			}
+
+			if field, exist := fields[path]; !exist || !field.sortable {
+				fieldErrors = append(fieldErrors, entity.FieldError{
+					Field:   "sort",
+					Value:   item,
+					Message: "is not sortable",
+				})
+				continue
+			}
+
+			filter.Sorts = append(filter.Sorts, pipeline.Sort{SortBy: path, SortOrder: order})
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return nil, entity.InvalidFields("Invalid filter", fieldErrors)
+	}
+
+	return filter, nil
+}
+
+// filterFieldsOf reads the allowlist declared on t, including embedded structs.
+func filterFieldsOf(t reflect.Type) map[string]filterField {
+	fields := map[string]filterField{}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		path := field.Tag.Get("filter")
+		if path == "" {
+			if field.Anonymous {
+				for key, embedded := range filterFieldsOf(field.Type) {
+					fields[key] = embedded
+				}
+			}
+			continue
+		}
+
+		valueType := field.Type
+		for valueType.Kind() == reflect.Ptr || valueType.Kind() == reflect.Slice {
+			valueType = valueType.Elem()
+		}
+
+		operators := map[pipeline.FilterOperator]bool{}
+		for _, name := range strings.Split(field.Tag.Get("ops"), ",") {
+			if operator, exist := pipeline.GetFilterOperator(strings.TrimSpace(name)); exist {
+				operators[operator] = true
+			}
+		}
+		if len(operators) == 0 {
+			operators[pipeline.FilterEq] = true
+		}
+
+		fields[path] = filterField{
+			valueType: valueType,
+			operators: operators,
+			sortable:  field.Tag.Get("sort") == "true",
+		}
+	}
+
+	return fields
+}
+
+// parseFilterKey splits "filter[path][operator]" into its path and operator.
+func parseFilterKey(key string) (string, string, bool) {
+	rest, found := strings.CutPrefix(key, "filter[")
+	if !found {
+		return "", "", false
+	}
+
+	path, rest, found := strings.Cut(rest, "]")
+	if !found || path == "" {
+		return "", "", false
+	}
+	if rest == "" {
+		return path, string(pipeline.FilterEq), true
+	}
+
+	operator, found := strings.CutPrefix(rest, "[")
+	if !found || !strings.HasSuffix(operator, "]") {
+		return "", "", false
+	}
+
+	return path, strings.TrimSuffix(operator, "]"), true
+}
+
+// parseFilterValue converts raw to the value expected by the operator.
+func parseFilterValue(valueType reflect.Type, operator pipeline.FilterOperator, raw []string) (interface{}, error) {
+	switch operator {
+	case pipeline.FilterRegex:
+		return strings.TrimSpace(raw[len(raw)-1]), nil
+
+	case pipeline.FilterExists:
+		value := reflect.New(reflect.TypeOf(true)).Elem()
+		if err := setQueryScalar(value, raw[len(raw)-1], ""); err != nil {
+			return nil, err
+		}
+		return value.Interface(), nil
+
+	case pipeline.FilterIn, pipeline.FilterNin:
+		slice := reflect.New(reflect.SliceOf(valueType)).Elem()
+		if err := setQuerySlice(slice, raw, ""); err != nil {
+			return nil, err
+		}
+
+		values := make([]interface{}, 0, slice.Len())
+		for i := 0; i < slice.Len(); i++ {
+			values = append(values, slice.Index(i).Interface())
+		}
+		return values, nil
+
+	default:
+		value := reflect.New(valueType).Elem()
+		if err := setQueryScalar(value, raw[len(raw)-1], ""); err != nil {
+			return nil, err
+		}
+		return value.Interface(), nil
+	}
+}
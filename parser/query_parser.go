@@ -2,10 +2,16 @@ package parser
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/susatyo441/go-ta-utils/entity"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -20,6 +26,7 @@ const (
 	TransformArray         QueryTransform = "array"
 	TransformObjectID      QueryTransform = "objectId"
 	TransformObjectIDArray QueryTransform = "objectIdArray"
+	TransformTime          QueryTransform = "time"
 )
 
 var queryTransformMap = map[string]QueryTransform{
@@ -31,91 +38,471 @@ var queryTransformMap = map[string]QueryTransform{
 	"array":         TransformArray,
 	"objectId":      TransformObjectID,
 	"objectIdArray": TransformObjectIDArray,
+	"time":          TransformTime,
+}
+
+// QueryTransformFunc converts a single query value for a custom transform.
+// The returned value must be assignable or convertible to the field (or slice element) type.
+type QueryTransformFunc func(value string) (interface{}, error)
+
+var (
+	customQueryTransforms   = map[QueryTransform]QueryTransformFunc{}
+	customQueryTransformsMu sync.RWMutex
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+// errUnsupportedQueryField is returned for fields ParseQuery cannot fill.
+// It is a programming error, so it is never reported as a field error.
+var errUnsupportedQueryField = errors.New("unsupported query field type")
+
+// RegisterQueryTransform registers a custom transform usable in the "transform" tag.
+// Intended to be called during initialization. Panics if name is a built-in transform.
+//
+// EXAMPLE:
+//
+//	parser.RegisterQueryTransform("phone", func(value string) (interface{}, error) {
+//		if !strings.HasPrefix(value, "+62") {
+//			return nil, errors.New("must start with +62")
+//		}
+//		return value, nil
+//	})
+//
+//	type UserQuery struct {
+//		Phone string `json:"phone" transform:"phone"`
+//	}
+func RegisterQueryTransform(name string, fn QueryTransformFunc) {
+	if _, exist := queryTransformMap[name]; exist {
+		panic("parser: cannot override built-in query transform " + name)
+	}
+
+	customQueryTransformsMu.Lock()
+	defer customQueryTransformsMu.Unlock()
+	customQueryTransforms[QueryTransform(name)] = fn
+}
+
+func getCustomQueryTransform(transform QueryTransform) (QueryTransformFunc, bool) {
+	customQueryTransformsMu.RLock()
+	defer customQueryTransformsMu.RUnlock()
+	fn, exist := customQueryTransforms[transform]
+	return fn, exist
 }
 
 func getQueryTransform(transform string) (QueryTransform, error) {
-	transformType, exist := queryTransformMap[transform]
-	if !exist {
-		return "", fmt.Errorf("invalid query transform: %s", transform)
+	if transformType, exist := queryTransformMap[transform]; exist {
+		return transformType, nil
+	}
+	if _, exist := getCustomQueryTransform(QueryTransform(transform)); exist {
+		return QueryTransform(transform), nil
 	}
-	return transformType, nil
+	return "", fmt.Errorf("invalid query transform: %s", transform)
 }
 
-func transformQueryValue(value string, transform string) interface{} {
-	transformType, err := getQueryTransform(transform)
-	if err != nil {
-		fmt.Println("Error getting query transform:", err)
-		return value
-	}
-
-	switch transformType {
-	case TransformInt:
-		var v, _ = strconv.Atoi(value)
-		return v
-	case TransformFloat32:
-		var v, _ = strconv.ParseFloat(value, 32)
-		return v
-	case TransformFloat64:
-		var v, _ = strconv.ParseFloat(value, 64)
-		return v
-	case TransformBool:
-		var v, err = strconv.ParseBool(value)
-		if err != nil {
-			return nil //if value isnt string "false", (example: nil) it should return nil (case: optionally filtering boolean)
+type ParseQueryOptions struct {
+	// Strict reports every value that cannot be converted as an entity.InvalidFields error
+	// instead of leaving the field at its zero value.
+	Strict bool
+}
+
+// queryParser holds the state of a single ParseQuery call.
+type queryParser struct {
+	query       url.Values
+	options     ParseQueryOptions
+	fieldErrors []entity.FieldError
+}
+
+// ParseQuery parses a query map (e.g. ctx.Queries()) into T.
+//
+// Use ParseQueryValues when the same key can be repeated (?ids=a&ids=b),
+// since a map[string]string only keeps one value per key.
+func ParseQuery[T any](query map[string]string, opts ...ParseQueryOptions) (*T, error) {
+	values := url.Values{}
+	for key, value := range query {
+		values.Set(key, value)
+	}
+
+	return ParseQueryValues[T](values, opts...)
+}
+
+// ParseQueryValues parses query values into T, field by field.
+//
+// Supported tags:
+//   - json: the query key. Defaults to the field name.
+//   - transform: optional, see QueryTransform and RegisterQueryTransform.
+//     When omitted the conversion follows the field type.
+//   - default: value used when the key is absent or empty.
+//
+// Supported fields:
+//   - string, bool, int*, uint*, float*, primitive.ObjectID.
+//   - time.Time, from epoch milliseconds (?from=1735689600000) or RFC3339 (?from=2025-01-01T00:00:00%2B07:00).
+//     An unescaped "+" of the offset, decoded as a space, is accepted too.
+//   - Slices of the above, from repeated keys (?ids=a&ids=b), comma-separated lists (?ids=a,b)
+//     or a JSON array (?ids=["a","b"]).
+//   - Pointers of the above, left nil when the key is absent.
+//   - Embedded structs, parsed as if their fields were declared on the parent (e.g. pipeline.PaginationQuery).
+//   - Nested structs, parsed from "parent.child" or "parent[child]" keys.
+//
+// A value that cannot be converted leaves the field at its zero value, unless
+// ParseQueryOptions.Strict is set. In strict mode every invalid field is collected
+// and returned at once as an *entity.HttpError (400) listing the fields.
+//
+// EXAMPLE:
+//
+//	type ProductQuery struct {
+//		pipeline.PaginationQuery
+//		Search     string               `json:"search"`
+//		Categories []primitive.ObjectID `json:"categories"`
+//		From       *time.Time           `json:"from"`
+//		SortBy     string               `json:"sortBy" default:"createdAt"`
+//	}
+func ParseQueryValues[T any](query url.Values, opts ...ParseQueryOptions) (*T, error) {
+	var parsedData T
+
+	value := reflect.ValueOf(&parsedData).Elem()
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("query can only be parsed into a struct, got %s", value.Kind())
+	}
+
+	p := &queryParser{query: query}
+	if len(opts) > 0 {
+		p.options = opts[0]
+	}
+
+	if _, err := p.parseStruct(value, ""); err != nil {
+		return nil, err
+	}
+
+	if len(p.fieldErrors) > 0 {
+		return nil, entity.InvalidFields("Invalid query parameters", p.fieldErrors)
+	}
+
+	return &parsedData, nil
+}
+
+// parseStruct fills the fields of v and reports whether any of them was set.
+func (p *queryParser) parseStruct(v reflect.Value, prefix string) (bool, error) {
+	t := v.Type()
+	anySet := false
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldValue := v.Field(i)
+
+		key, skip := queryKey(field)
+		if skip {
+			continue
+		}
+
+		// Embedded structs share the parent's keys.
+		if field.Anonymous && key == "" && isNestedStruct(field.Type) {
+			set, err := p.parseNested(fieldValue, prefix)
+			if err != nil {
+				return false, err
+			}
+			anySet = anySet || set
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if key == "" {
+			key = field.Name
+		}
+		fullKey := key
+		if prefix != "" {
+			fullKey = prefix + "." + key
 		}
-		return v
-	case TransformArray:
-		var v []interface{}
-		json.Unmarshal([]byte(value), &v)
-		return v
-	case TransformObjectID:
-		var v, _ = primitive.ObjectIDFromHex(value)
-		return v
-	case TransformObjectIDArray:
-		var v []interface{}
-		var objectIdArr []primitive.ObjectID
 
-		json.Unmarshal([]byte(value), &v)
+		transform := field.Tag.Get("transform")
 
-		for _, val := range v {
-			objectId, err := primitive.ObjectIDFromHex(val.(string))
+		if transform == "" && isNestedStruct(field.Type) {
+			set, err := p.parseNested(fieldValue, fullKey)
 			if err != nil {
-				return v
+				return false, err
+			}
+			anySet = anySet || set
+			continue
+		}
+
+		raw := lookupQuery(p.query, fullKey)
+		if len(raw) == 0 {
+			if defaultValue, ok := field.Tag.Lookup("default"); ok {
+				raw = []string{defaultValue}
+			}
+		}
+		if len(raw) == 0 {
+			continue
+		}
+
+		if transform != "" {
+			if _, err := getQueryTransform(transform); err != nil {
+				return false, err
 			}
+		}
+
+		err := setQueryField(fieldValue, raw, QueryTransform(transform))
+		if errors.Is(err, errUnsupportedQueryField) {
+			return false, fmt.Errorf("%s: %w", fullKey, err)
+		}
+
+		// Outside strict mode, conversion errors leave the field at its zero value.
+		if err != nil {
+			if p.options.Strict {
+				p.fieldErrors = append(p.fieldErrors, entity.FieldError{
+					Field:   fullKey,
+					Value:   strings.Join(raw, ","),
+					Message: err.Error(),
+				})
+			}
+			continue
+		}
+
+		anySet = true
+	}
+
+	return anySet, nil
+}
+
+// parseNested parses a (possibly pointer) struct field. Pointers are only
+// allocated when at least one of their fields is present in the query.
+func (p *queryParser) parseNested(v reflect.Value, prefix string) (bool, error) {
+	if v.Kind() != reflect.Ptr {
+		return p.parseStruct(v, prefix)
+	}
+
+	nested := reflect.New(v.Type().Elem())
+	set, err := p.parseStruct(nested.Elem(), prefix)
+	if err != nil {
+		return false, err
+	}
+	if set && v.CanSet() {
+		v.Set(nested)
+	}
+
+	return set, nil
+}
+
+// queryKey returns the key from the json tag, and whether the field must be skipped.
+func queryKey(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+
+	name, _, _ := strings.Cut(tag, ",")
+	return name, false
+}
+
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Struct && t != timeType && t != objectIDType
+}
+
+// lookupQuery returns the non-empty values of "a.b", falling back to "a[b]".
+func lookupQuery(query url.Values, key string) []string {
+	values := nonEmpty(query[key])
+	if len(values) == 0 && strings.Contains(key, ".") {
+		parts := strings.Split(key, ".")
+		values = nonEmpty(query[parts[0]+"["+strings.Join(parts[1:], "][")+"]"])
+	}
+
+	return values
+}
+
+func nonEmpty(values []string) []string {
+	result := []string{}
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
 
-			objectIdArr = append(objectIdArr, objectId)
+// setQueryField converts raw into the type of v and assigns it.
+func setQueryField(v reflect.Value, raw []string, transform QueryTransform) error {
+	switch {
+	case v.Kind() == reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setQueryField(elem.Elem(), raw, transform); err != nil {
+			return err
 		}
+		v.Set(elem)
+		return nil
+
+	case v.Kind() == reflect.Slice:
+		return setQuerySlice(v, raw, transform)
+
+	case v.Kind() == reflect.Interface && transform == TransformArray:
+		return setQuerySlice(v, raw, transform)
 
-		return objectIdArr
 	default:
-		return value
+		return setQueryScalar(v, raw[len(raw)-1], transform)
 	}
 }
 
-func ParseQuery[T any](query map[string]string) (*T, error) {
-	t := reflect.TypeFor[T]()
+func setQuerySlice(v reflect.Value, raw []string, transform QueryTransform) error {
+	sliceType := v.Type()
+	if sliceType.Kind() != reflect.Slice {
+		sliceType = reflect.TypeOf([]interface{}{})
+	}
 
-	parsedQuery := map[string]interface{}{}
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
+	items := []string{}
+	for _, value := range raw {
+		value = strings.TrimSpace(value)
 
-		key := field.Tag.Get("json")
-		transform := field.Tag.Get("transform")
-		parsedQuery[key] = transformQueryValue(query[key], transform)
+		// JSON arrays are kept for backward compatibility (?ids=["a","b"]).
+		if strings.HasPrefix(value, "[") {
+			var decoded []interface{}
+			if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+				return errors.New("must be a valid list")
+			}
+			for _, item := range decoded {
+				items = append(items, fmt.Sprint(item))
+			}
+			continue
+		}
+
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
 	}
 
-	jsonData, err := json.Marshal(parsedQuery)
-	if err != nil {
-		fmt.Println("Error marshaling JSON:", err)
-		return nil, err
+	elemTransform := transform
+	switch transform {
+	case TransformArray:
+		elemTransform = ""
+	case TransformObjectIDArray:
+		elemTransform = TransformObjectID
 	}
 
-	var parsedData T
-	err = json.Unmarshal(jsonData, &parsedData)
+	slice := reflect.MakeSlice(sliceType, 0, len(items))
+	for _, item := range items {
+		elem := reflect.New(sliceType.Elem()).Elem()
+		if err := setQueryScalar(elem, item, elemTransform); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, elem)
+	}
+
+	v.Set(slice)
+	return nil
+}
+
+func setQueryScalar(v reflect.Value, value string, transform QueryTransform) error {
+	value = strings.TrimSpace(value)
+
+	if fn, exist := getCustomQueryTransform(transform); exist {
+		return setQueryCustom(v, value, fn)
+	}
+
+	switch {
+	case v.Type() == timeType:
+		parsed, err := parseQueryTime(value)
+		if err != nil {
+			return errors.New("must be epoch milliseconds or an RFC3339 date")
+		}
+		v.Set(reflect.ValueOf(parsed))
+		return nil
+
+	case v.Type() == objectIDType:
+		oid, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return errors.New("must be a valid ObjectId")
+		}
+		v.Set(reflect.ValueOf(oid))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if transform == TransformObjectID && !primitive.IsValidObjectID(value) {
+			return errors.New("must be a valid ObjectId")
+		}
+		v.SetString(value)
+
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		v.SetBool(parsed)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		v.SetInt(parsed)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a positive integer")
+		}
+		v.SetUint(parsed)
+
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		v.SetFloat(parsed)
+
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(value))
+
+	default:
+		return fmt.Errorf("%w: %s", errUnsupportedQueryField, v.Type())
+	}
+
+	return nil
+}
+
+// setQueryCustom assigns the result of a registered QueryTransformFunc.
+func setQueryCustom(v reflect.Value, value string, fn QueryTransformFunc) error {
+	result, err := fn(value)
 	if err != nil {
-		fmt.Println("Error unmarshaling JSON:", err)
-		return nil, err
+		return err
+	}
+	if result == nil {
+		return nil
 	}
 
-	return &parsedData, nil
+	resultValue := reflect.ValueOf(result)
+	switch {
+	case resultValue.Type().AssignableTo(v.Type()):
+		v.Set(resultValue)
+	case resultValue.Type().ConvertibleTo(v.Type()):
+		v.Set(resultValue.Convert(v.Type()))
+	default:
+		return fmt.Errorf("%w: transform returned %s for %s", errUnsupportedQueryField, resultValue.Type(), v.Type())
+	}
+
+	return nil
+}
+
+// parseQueryTime parses epoch milliseconds or an RFC3339 timestamp.
+func parseQueryTime(value string) (time.Time, error) {
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(millis), nil
+	}
+
+	// "+07:00" sent unescaped is decoded as " 07:00".
+	if index := strings.LastIndex(value, " "); index > 0 {
+		value = value[:index] + "+" + value[index+1:]
+	}
+
+	return time.Parse(time.RFC3339, value)
 }
@@ -0,0 +1,252 @@
+package pipeline
+
+import (
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Condition is an optional predicate on a single field, e.g. {"$gte": 1000}.
+// A nil Condition means "no filter" and is omitted from the final $match,
+// unlike the "always true" sentinel of the Generate* helpers.
+type Condition bson.M
+
+// matchNothing is used for invalid input that must not match any document.
+func matchNothing() Condition {
+	return Condition{"$in": bson.A{}}
+}
+
+// RangeCondition is the optional version of GenerateRangeQuery.
+// [min, max], [-1, max] or [min] with negative bounds ignored.
+func RangeCondition(filter []int) Condition {
+	if len(filter) == 2 && filter[0] >= 0 && filter[1] >= 0 {
+		return Condition{"$gte": filter[0], "$lte": filter[1]}
+	}
+
+	if len(filter) == 2 && filter[1] >= 0 {
+		return Condition{"$lte": filter[1]}
+	}
+
+	if len(filter) == 1 && filter[0] >= 0 {
+		return Condition{"$gte": filter[0]}
+	}
+
+	return nil
+}
+
+// RangeConditionTwoVar is the optional version of GenerateRangeQueryTwoVar.
+// Zero bounds are ignored.
+func RangeConditionTwoVar(filter1 int, filter2 int) Condition {
+	if filter1 != 0 && filter2 != 0 {
+		return Condition{"$gte": filter1, "$lte": filter2}
+	}
+
+	if filter2 != 0 {
+		return Condition{"$lte": filter2}
+	}
+
+	if filter1 != 0 {
+		return Condition{"$gte": filter1}
+	}
+
+	return nil
+}
+
+// ExactCondition is the optional version of GenerateExactFilter.
+func ExactCondition[T comparable](condition bool, filter T) Condition {
+	if !condition {
+		return nil
+	}
+
+	return Condition{"$eq": filter}
+}
+
+// ObjectIdCondition is the optional version of GenerateObjectIdFilter.
+// An empty string is no filter, an invalid ObjectId matches nothing.
+func ObjectIdCondition(filter string) Condition {
+	if filter == "" {
+		return nil
+	}
+
+	objId, err := primitive.ObjectIDFromHex(filter)
+	if err != nil {
+		return matchNothing()
+	}
+
+	return Condition{"$eq": objId}
+}
+
+// ArrayCondition is the optional version of GenerateArrayFilter.
+// An empty slice is no filter.
+func ArrayCondition[T comparable](filter []T) Condition {
+	if len(filter) == 0 {
+		return nil
+	}
+
+	return Condition{"$in": filter}
+}
+
+// SearchCondition is the optional version of GenerateSearchCondition.
+func SearchCondition(search string) Condition {
+	if search == "" {
+		return nil
+	}
+
+	return Condition{"$regex": search, "$options": "i"}
+}
+
+// DateCondition is the optional version of GenerateDateFilter.
+// [start, end] in epoch milliseconds, or [start] for the day starting at start.
+// Use DateConditionIn to align days to the store timezone.
+func DateCondition(requestDate []int) Condition {
+	if len(requestDate) == 2 && requestDate[0] >= 0 && requestDate[1] >= 0 {
+		return Condition{
+			"$gte": time.UnixMilli(int64(requestDate[0])),
+			"$lte": time.UnixMilli(int64(requestDate[1])),
+		}
+	}
+
+	if len(requestDate) == 1 && requestDate[0] >= 0 {
+		start := time.UnixMilli(int64(requestDate[0]))
+
+		return Condition{
+			"$gte": start,
+			"$lt":  start.Add(24*time.Hour - time.Second),
+		}
+	}
+
+	return nil
+}
+
+// ConditionBuilder composes optional conditions into a single $match filter.
+// Absent (nil) conditions are left out entirely, so indexes are still used.
+//
+// EXAMPLE:
+//
+//	match := pipeline.NewConditionBuilder().
+//		Where("storeId", pipeline.ExactCondition(true, storeId)).
+//		Where("category._id", pipeline.ArrayCondition(query.Categories)).
+//		Where("price", pipeline.RangeCondition(query.Price)).
+//		Search(query.Search, []string{"name", "category.name"})
+//
+//	pipeline.NewPipelineBuilder().MatchConditions(match)
+//	// OR
+//	productService.Find(ctx, match.Build())
+type ConditionBuilder struct {
+	filter bson.M
+	ors    []bson.A
+}
+
+// NewConditionBuilder is a constructor to initialize ConditionBuilder
+func NewConditionBuilder() *ConditionBuilder {
+	return &ConditionBuilder{
+		filter: bson.M{},
+	}
+}
+
+// Where adds the condition on field, skipped when the condition is nil.
+// Conditions on the same field are merged.
+func (cb *ConditionBuilder) Where(field string, condition Condition) *ConditionBuilder {
+	if condition == nil {
+		return cb
+	}
+
+	existing, _ := cb.filter[field].(bson.M)
+	if existing == nil {
+		existing = bson.M{}
+	}
+	for operator, value := range condition {
+		existing[operator] = value
+	}
+	cb.filter[field] = existing
+
+	return cb
+}
+
+// WhereAny adds {"$or": [{field: condition}, ...]} for every present condition.
+// Skipped when none of the conditions are present.
+func (cb *ConditionBuilder) WhereAny(conditions map[string]Condition) *ConditionBuilder {
+	fields := make([]string, 0, len(conditions))
+	for field := range conditions {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	or := bson.A{}
+	for _, field := range fields {
+		if condition := conditions[field]; condition != nil {
+			or = append(or, bson.M{field: bson.M(condition)})
+		}
+	}
+
+	if len(or) > 0 {
+		cb.ors = append(cb.ors, or)
+	}
+
+	return cb
+}
+
+// Search matches keyword case-insensitively in any of the fields. Skipped when keyword is empty.
+func (cb *ConditionBuilder) Search(keyword string, searchFields []string) *ConditionBuilder {
+	if keyword == "" || len(searchFields) == 0 {
+		return cb
+	}
+
+	or := bson.A{}
+	for _, field := range searchFields {
+		or = append(or, bson.M{field: bson.M(SearchCondition(keyword))})
+	}
+	cb.ors = append(cb.ors, or)
+
+	return cb
+}
+
+// IsEmpty reports whether no condition has been added.
+func (cb *ConditionBuilder) IsEmpty() bool {
+	return len(cb.filter) == 0 && len(cb.ors) == 0
+}
+
+// Build returns the composed filter, an empty filter when no condition is present.
+func (cb *ConditionBuilder) Build() bson.M {
+	filter := bson.M{}
+	for field, condition := range cb.filter {
+		filter[field] = condition
+	}
+
+	switch len(cb.ors) {
+	case 0:
+	case 1:
+		filter["$or"] = cb.ors[0]
+	default:
+		and := bson.A{}
+		for _, or := range cb.ors {
+			and = append(and, bson.M{"$or": or})
+		}
+		filter["$and"] = and
+	}
+
+	return filter
+}
+
+// MatchConditions adds a $match stage built from the conditions.
+// Nothing is added when no condition is present.
+func (pb *PipelineBuilder) MatchConditions(conditions *ConditionBuilder) *PipelineBuilder {
+	if conditions != nil && !conditions.IsEmpty() {
+		pb.pipelines = append(pb.pipelines, bson.D{
+			{Key: "$match", Value: conditions.Build()},
+		})
+	}
+	return pb
+}
+
+// OptionalWhere adds a {field: condition} $match stage, skipped when the condition is nil.
+func (pb *PipelineBuilder) OptionalWhere(field string, condition Condition) *PipelineBuilder {
+	if condition != nil {
+		pb.pipelines = append(pb.pipelines, bson.D{
+			{Key: "$match", Value: bson.M{field: bson.M(condition)}},
+		})
+	}
+	return pb
+}
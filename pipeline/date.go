@@ -0,0 +1,239 @@
+package pipeline
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// IANA names of the Indonesian timezones.
+const (
+	TimezoneWIB  = "Asia/Jakarta"
+	TimezoneWITA = "Asia/Makassar"
+	TimezoneWIT  = "Asia/Jayapura"
+)
+
+// Indonesian timezones have no DST, so fixed offsets are exact.
+// Used when the host has no tzdata (e.g. scratch images).
+var fixedTimezones = map[string]*time.Location{
+	TimezoneWIB:  time.FixedZone("WIB", 7*60*60),
+	TimezoneWITA: time.FixedZone("WITA", 8*60*60),
+	TimezoneWIT:  time.FixedZone("WIT", 9*60*60),
+}
+
+// LoadTimezone loads an IANA timezone, e.g. TimezoneWIB. An empty name is UTC.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		if fixed, exist := fixedTimezones[name]; exist {
+			return fixed, nil
+		}
+		return nil, err
+	}
+
+	return loc, nil
+}
+
+// StartOfDay returns midnight of the day containing t, in loc.
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// DateConditionIn is the timezone-aware version of DateCondition, covering whole days in loc.
+// [start, end] in epoch milliseconds matches from the start of start's day until the end of end's day,
+// [start] matches start's day. Days are [midnight, next midnight), not 24h - 1s.
+//
+// EXAMPLE:
+//
+//	wib, _ := pipeline.LoadTimezone(pipeline.TimezoneWIB)
+//	pipeline.NewConditionBuilder().Where("createdAt", pipeline.DateConditionIn(query.Date, wib))
+func DateConditionIn(requestDate []int, loc *time.Location) Condition {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var start, end time.Time
+	switch {
+	case len(requestDate) == 2 && requestDate[0] >= 0 && requestDate[1] >= 0:
+		start = StartOfDay(time.UnixMilli(int64(requestDate[0])), loc)
+		end = StartOfDay(time.UnixMilli(int64(requestDate[1])), loc).AddDate(0, 0, 1)
+	case len(requestDate) == 1 && requestDate[0] >= 0:
+		start = StartOfDay(time.UnixMilli(int64(requestDate[0])), loc)
+		end = start.AddDate(0, 0, 1)
+	default:
+		return nil
+	}
+
+	return Condition{"$gte": start, "$lt": end}
+}
+
+// DateUnit is the size of a date bucket.
+type DateUnit string
+
+const (
+	DateUnitHour  DateUnit = "hour"
+	DateUnitDay   DateUnit = "day"
+	DateUnitWeek  DateUnit = "week"
+	DateUnitMonth DateUnit = "month"
+)
+
+type DateBucketOptions struct {
+	// Date field to bucket, e.g. "createdAt".
+	Field string
+	// Bucket size. Defaults to DateUnitDay.
+	Unit DateUnit
+	// IANA timezone the buckets are aligned to, e.g. TimezoneWIB. Defaults to UTC.
+	Timezone string
+	// First day of DateUnitWeek buckets. Defaults to Sunday.
+	StartOfWeek time.Weekday
+	// Range of the empty buckets to create. Without it only the gaps
+	// between the first and last bucket are filled.
+	From time.Time
+	To   time.Time
+	// Accumulated fields set to 0 in empty buckets, e.g. "totalPrice".
+	Fill []string
+	// Output field of the bucket start. Defaults to "date".
+	As string
+}
+
+// GroupByDate groups documents into date buckets aligned to a timezone, and creates empty buckets for charts.
+// Each output document has the bucket start (a real instant) in opts.As, plus the accumulators.
+//
+// Buckets are computed on the local wall-clock time, so months and weeks follow the local calendar.
+// Requires MongoDB 5.3+ ($dateTrunc, $densify, $fill).
+//
+// EXAMPLE:
+//
+//	pipeline.NewPipelineBuilder().
+//		MatchConditions(match).
+//		GroupByDate(pipeline.DateBucketOptions{
+//			Field:    "createdAt",
+//			Unit:     pipeline.DateUnitDay,
+//			Timezone: pipeline.TimezoneWIB,
+//			From:     from,
+//			To:       to,
+//			Fill:     []string{"totalPrice", "count"},
+//		}, bson.M{
+//			"totalPrice": bson.M{"$sum": "$totalPrice"},
+//			"count":      bson.M{"$sum": 1},
+//		})
+func (pb *PipelineBuilder) GroupByDate(opts DateBucketOptions, accumulators bson.M) *PipelineBuilder {
+	unit := opts.Unit
+	if unit == "" {
+		unit = DateUnitDay
+	}
+	timezone := opts.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	as := opts.As
+	if as == "" {
+		as = "date"
+	}
+	startOfWeek := strings.ToLower(opts.StartOfWeek.String())
+	field := "$" + strings.TrimPrefix(opts.Field, "$")
+
+	// Local wall-clock time of the document, stored as if it were UTC.
+	wallClock := bson.M{"$dateFromParts": bson.M{
+		"year":  bson.M{"$year": bson.M{"date": field, "timezone": timezone}},
+		"month": bson.M{"$month": bson.M{"date": field, "timezone": timezone}},
+		"day":   bson.M{"$dayOfMonth": bson.M{"date": field, "timezone": timezone}},
+		"hour":  bson.M{"$hour": bson.M{"date": field, "timezone": timezone}},
+	}}
+
+	trunc := bson.M{"date": wallClock, "unit": string(unit)}
+	if unit == DateUnitWeek {
+		trunc["startOfWeek"] = startOfWeek
+	}
+
+	keys := make([]string, 0, len(accumulators))
+	for key := range accumulators {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	group := bson.D{{Key: "_id", Value: bson.M{"$dateTrunc": trunc}}}
+	for _, key := range keys {
+		group = append(group, bson.E{Key: key, Value: accumulators[key]})
+	}
+
+	bounds := interface{}("full")
+	if !opts.From.IsZero() && !opts.To.IsZero() {
+		loc, err := LoadTimezone(opts.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+
+		lower := truncateWallClock(opts.From.In(loc), unit, opts.StartOfWeek)
+		upper := addDateUnit(truncateWallClock(opts.To.In(loc), unit, opts.StartOfWeek), unit)
+		bounds = bson.A{lower, upper}
+	}
+
+	pb.Group(group)
+	pb.Set(bson.M{as: "$_id"})
+	pb.Project(bson.M{"_id": 0})
+	pb.pipelines = append(pb.pipelines, bson.D{
+		{Key: "$densify", Value: bson.M{
+			"field": as,
+			"range": bson.M{"step": 1, "unit": string(unit), "bounds": bounds},
+		}},
+	})
+
+	if len(opts.Fill) > 0 {
+		output := bson.M{}
+		for _, key := range opts.Fill {
+			output[key] = bson.M{"value": 0}
+		}
+		pb.pipelines = append(pb.pipelines, bson.D{
+			{Key: "$fill", Value: bson.M{"output": output}},
+		})
+	}
+
+	// Back from wall-clock time to the real instant in the timezone.
+	pb.Set(bson.M{as: bson.M{"$dateFromParts": bson.M{
+		"year":     bson.M{"$year": "$" + as},
+		"month":    bson.M{"$month": "$" + as},
+		"day":      bson.M{"$dayOfMonth": "$" + as},
+		"hour":     bson.M{"$hour": "$" + as},
+		"timezone": timezone,
+	}}})
+	pb.Sort(bson.M{as: 1})
+
+	return pb
+}
+
+// truncateWallClock truncates the wall-clock time of t to unit, returned as UTC like $dateTrunc on wallClock.
+func truncateWallClock(t time.Time, unit DateUnit, startOfWeek time.Weekday) time.Time {
+	switch unit {
+	case DateUnitHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.UTC)
+	case DateUnitWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		offset := (int(day.Weekday()) - int(startOfWeek) + 7) % 7
+		return day.AddDate(0, 0, -offset)
+	case DateUnitMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func addDateUnit(t time.Time, unit DateUnit) time.Time {
+	switch unit {
+	case DateUnitHour:
+		return t.Add(time.Hour)
+	case DateUnitWeek:
+		return t.AddDate(0, 0, 7)
+	case DateUnitMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FilterOperator is a comparison operator of the filter DSL (?filter[price][gte]=1000).
+type FilterOperator string
+
+const (
+	FilterEq     FilterOperator = "eq"
+	FilterNe     FilterOperator = "ne"
+	FilterGt     FilterOperator = "gt"
+	FilterGte    FilterOperator = "gte"
+	FilterLt     FilterOperator = "lt"
+	FilterLte    FilterOperator = "lte"
+	FilterIn     FilterOperator = "in"
+	FilterNin    FilterOperator = "nin"
+	FilterRegex  FilterOperator = "regex"
+	FilterExists FilterOperator = "exists"
+)
+
+var filterOperatorMap = map[string]FilterOperator{
+	"eq":     FilterEq,
+	"ne":     FilterNe,
+	"gt":     FilterGt,
+	"gte":    FilterGte,
+	"lt":     FilterLt,
+	"lte":    FilterLte,
+	"in":     FilterIn,
+	"nin":    FilterNin,
+	"regex":  FilterRegex,
+	"exists": FilterExists,
+}
+
+// GetFilterOperator returns the operator matching name, and whether it exists.
+func GetFilterOperator(name string) (FilterOperator, bool) {
+	operator, exist := filterOperatorMap[name]
+	return operator, exist
+}
+
+// FilterCondition is a single "field operator value" predicate.
+// Value is a []interface{} for FilterIn and FilterNin.
+type FilterCondition struct {
+	Field    string
+	Operator FilterOperator
+	Value    interface{}
+}
+
+// Filter is the parsed form of the filter DSL, see parser.ParseFilter.
+type Filter struct {
+	Conditions []FilterCondition
+	Sorts      []Sort
+}
+
+// ToMatch compiles the conditions into a Mongo filter, usable in a $match stage
+// or as the filter of BaseService.Find. Returns an empty filter when there are no conditions.
+//
+// Conditions on the same field are merged ({"price": {"$gte": 1000, "$lte": 2000}}).
+// Regex values are matched literally and case-insensitively.
+func (f *Filter) ToMatch() bson.M {
+	match := bson.M{}
+	if f == nil {
+		return match
+	}
+
+	for _, condition := range f.Conditions {
+		operators, _ := match[condition.Field].(bson.M)
+		if operators == nil {
+			operators = bson.M{}
+		}
+
+		switch condition.Operator {
+		case FilterRegex:
+			operators["$regex"] = regexp.QuoteMeta(condition.Value.(string))
+			operators["$options"] = "i"
+		default:
+			operators["$"+string(condition.Operator)] = condition.Value
+		}
+
+		match[condition.Field] = operators
+	}
+
+	return match
+}
+
+// ToSort compiles the sorts into a sort document, usable in a $sort stage
+// or in options.Find().SetSort. Returns nil when there are no sorts.
+func (f *Filter) ToSort() bson.D {
+	if f == nil || len(f.Sorts) == 0 {
+		return nil
+	}
+
+	sort := bson.D{}
+	for _, s := range f.Sorts {
+		sort = append(sort, bson.E{Key: s.SortBy, Value: s.SortOrder})
+	}
+
+	return sort
+}
+
+// Filter adds a $match stage compiled from the filter DSL.
+// Nothing is added when the filter has no conditions.
+//
+// Sorting is left to Pagination, pass filter.Sorts as its custom sort:
+//
+//	pipeline.NewPipelineBuilder().
+//		Filter(filter).
+//		Pagination(query.PaginationQuery, filter.Sorts...)
+func (pb *PipelineBuilder) Filter(filter *Filter) *PipelineBuilder {
+	if filter != nil && len(filter.Conditions) > 0 {
+		pb.pipelines = append(pb.pipelines, bson.D{
+			{Key: "$match", Value: filter.ToMatch()},
+		})
+	}
+	return pb
+}
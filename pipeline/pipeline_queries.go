@@ -1,76 +1,47 @@
 package pipeline
 
 import (
-	"reflect"
 	"strings"
-	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func GenerateRangeQuery(filter []int) bson.M {
-	if len(filter) == 2 && filter[0] >= 0 && filter[1] >= 0 {
-		return bson.M{
-			"$gte": filter[0],
-			"$lte": filter[1],
-		}
-	}
+// alwaysTrue returns the legacy "no filter" sentinel of the Generate* helpers,
+// a new map each time since callers may add to the filter they get.
+func alwaysTrue() bson.M {
+	return bson.M{"$ne": "vdjfPyv7ijO5vQeLIZmQHuzPO"}
+}
 
-	if len(filter) == 2 && filter[1] >= 0 {
-		return bson.M{
-			"$lte": filter[1],
-		}
+// orAlwaysTrue converts an optional condition back to the legacy sentinel form.
+func orAlwaysTrue(condition Condition) bson.M {
+	if condition == nil {
+		return alwaysTrue()
 	}
 
-	if len(filter) == 1 && filter[0] >= 0 {
-		return bson.M{
-			"$gte": filter[0],
-		}
-	}
+	return bson.M(condition)
+}
 
-	return bson.M{
-		"$ne": "vdjfPyv7ijO5vQeLIZmQHuzPO",
-	}
+// Deprecated: use RangeCondition with ConditionBuilder, so an absent range is omitted from $match
+func GenerateRangeQuery(filter []int) bson.M {
+	return orAlwaysTrue(RangeCondition(filter))
 }
 
+// Deprecated: use RangeConditionTwoVar with ConditionBuilder, so an absent range is omitted from $match
 func GenerateRangeQueryTwoVar(filter1 int, filter2 int) bson.M {
-	if filter1 != 0 && filter2 != 0 {
-		return bson.M{
-			"$gte": filter1,
-			"$lte": filter2,
-		}
-	}
-
-	if filter2 != 0 {
-		return bson.M{
-			"$lte": filter2,
-		}
-	}
-
-	if filter1 != 0 {
-		return bson.M{
-			"$gte": filter1,
-		}
-	}
-
-	return bson.M{
-		"$ne": "vdjfPyv7ijO5vQeLIZmQHuzPO",
-	}
+	return orAlwaysTrue(RangeConditionTwoVar(filter1, filter2))
 }
 
+// Deprecated: use ExactCondition with ConditionBuilder, so an absent filter is omitted from $match
 func GenerateExactFilter[T comparable](condition bool, filter T) primitive.M {
-	if condition {
-		return bson.M{"$eq": filter}
-	} else {
-		return bson.M{"$ne": "vdjfPyv7ijO5vQeLIZmQHuzPO"} //random string so its always true
-	}
+	return orAlwaysTrue(ExactCondition(condition, filter))
 }
 
+// Deprecated: use ObjectIdCondition with ConditionBuilder, so an absent filter is omitted from $match
 func GenerateObjectIdFilter(filter string) primitive.M {
 	if filter == "" {
-		return bson.M{"$ne": "vdjfPyv7ijO5vQeLIZmQHuzPO"} //random string so its always true
+		return alwaysTrue()
 	}
 
 	objId, err := primitive.ObjectIDFromHex(filter)
@@ -81,16 +52,9 @@ func GenerateObjectIdFilter(filter string) primitive.M {
 	}
 }
 
+// Deprecated: use ArrayCondition with ConditionBuilder, so an absent filter is omitted from $match
 func GenerateArrayFilter[T comparable](filter []T) primitive.M {
-	var varType = reflect.TypeOf(filter).Kind()
-
-	//check if variable type is slice AND the variable have length.
-	//providing an empty slice will result to an error. (probably because it resolves as a zero value <nil>)
-	if varType == reflect.Slice && len(filter) > 0 {
-		return bson.M{"$in": filter}
-	} else {
-		return bson.M{"$ne": "vdjfPyv7ijO5vQeLIZmQHuzPO"} //random string so its always true
-	}
+	return orAlwaysTrue(ArrayCondition(filter))
 }
 
 func GenerateSearchCondition(search string) primitive.M {
@@ -101,36 +65,9 @@ func GenerateSearchCondition(search string) primitive.M {
 	}
 }
 
+// Deprecated: use DateCondition with ConditionBuilder, so an absent date range is omitted from $match
 func GenerateDateFilter(requestDate []int) primitive.M {
-	if len(requestDate) < 1 {
-		return bson.M{"$ne": "g#*a,Jvb&U.Bg"}
-	}
-
-	//No longer adds 24 hour minus one second to end. It should be added in FE
-	if len(requestDate) == 2 && requestDate[0] >= 0 && requestDate[1] >= 0 {
-		start := time.UnixMilli(int64(requestDate[0]))
-		end := time.UnixMilli(int64(requestDate[1]))
-
-		return bson.M{
-			"$gte": start,
-			"$lte": end,
-		}
-	}
-
-	//If one date passed, the second argument will be 24 hour minus one second (same day) after the date
-	if len(requestDate) == 1 && requestDate[0] >= 0 {
-		start := time.UnixMilli(int64(requestDate[0]))
-		start2 := time.UnixMilli(int64(requestDate[0]))
-
-		start2 = start2.Add(24*time.Hour - time.Second)
-
-		return bson.M{
-			"$gte": start,
-			"$lt":  start2,
-		}
-	}
-
-	return bson.M{"$ne": "g#*a,Jvb&U.Bg"}
+	return orAlwaysTrue(DateCondition(requestDate))
 }
 
 // Deprecated: use GenerateFacetOption func instead, so you no longer project the option with "$option._id"
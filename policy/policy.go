@@ -0,0 +1,291 @@
+package policy
+
+import (
+	"strings"
+
+	"github.com/susatyo441/go-ta-utils/entity"
+	"github.com/susatyo441/go-ta-utils/model"
+	"github.com/susatyo441/go-ta-utils/parser"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Role of a user in a store, stored in model.User.Roles.
+type Role string
+
+const (
+	RoleOwner   Role = "owner"
+	RoleManager Role = "manager"
+	RoleCashier Role = "cashier"
+)
+
+// Permission is "<resource>:<action>". "<resource>:*" and "*" are wildcards.
+type Permission string
+
+const (
+	PermissionAll Permission = "*"
+
+	PermissionProductRead               Permission = "product:read"
+	PermissionProductCreate             Permission = "product:create"
+	PermissionProductUpdate             Permission = "product:update"
+	PermissionProductUpdateCapitalPrice Permission = "product:update-capital-price"
+	PermissionProductDelete             Permission = "product:delete"
+
+	PermissionCategoryRead   Permission = "category:read"
+	PermissionCategoryCreate Permission = "category:create"
+	PermissionCategoryUpdate Permission = "category:update"
+	PermissionCategoryDelete Permission = "category:delete"
+
+	PermissionTransactionRead   Permission = "transaction:read"
+	PermissionTransactionCreate Permission = "transaction:create"
+	PermissionTransactionDelete Permission = "transaction:delete"
+
+	PermissionStoreRead   Permission = "store:read"
+	PermissionStoreUpdate Permission = "store:update"
+
+	PermissionUserRead   Permission = "user:read"
+	PermissionUserManage Permission = "user:manage"
+
+	PermissionReportRead Permission = "report:read"
+)
+
+// DefaultRolePermissions are the permissions of the built-in roles.
+var DefaultRolePermissions = map[Role][]Permission{
+	RoleOwner: {PermissionAll},
+	RoleManager: {
+		"product:*",
+		"category:*",
+		"transaction:*",
+		PermissionStoreRead,
+		PermissionUserRead,
+		PermissionReportRead,
+	},
+	RoleCashier: {
+		PermissionProductRead,
+		PermissionProductUpdate,
+		PermissionCategoryRead,
+		PermissionTransactionRead,
+		PermissionTransactionCreate,
+		PermissionStoreRead,
+	},
+}
+
+// ProductFieldPermissions are the product fields needing more than PermissionProductUpdate.
+var ProductFieldPermissions = map[string]Permission{
+	"capitalPrice":          PermissionProductUpdateCapitalPrice,
+	"variants.capitalPrice": PermissionProductUpdateCapitalPrice,
+}
+
+// Policy maps roles to permissions.
+type Policy struct {
+	rolePermissions map[Role][]Permission
+}
+
+// NewPolicy is a constructor to initialize Policy
+func NewPolicy(rolePermissions map[Role][]Permission) *Policy {
+	return &Policy{
+		rolePermissions: rolePermissions,
+	}
+}
+
+// DefaultPolicy is the policy of DefaultRolePermissions.
+func DefaultPolicy() *Policy {
+	return NewPolicy(DefaultRolePermissions)
+}
+
+// scopeRolePrefix marks the roles of ScopeRoles.
+const scopeRolePrefix = "scope:"
+
+// ScopeRoles returns the roles of an API key with scopes: each role grants its scope,
+// whatever the role permissions of the Policy. Use cases check them like the roles of users.
+func ScopeRoles(scopes []string) []Role {
+	roles := make([]Role, 0, len(scopes))
+	for _, scope := range scopes {
+		roles = append(roles, Role(scopeRolePrefix+scope))
+	}
+
+	return roles
+}
+
+// RolesOf returns the roles of user in store. Roles of ScopeRoles are never given to users.
+func RolesOf(user model.User, storeId primitive.ObjectID) []Role {
+	roles := []Role{}
+	for _, storeRole := range user.Roles {
+		if storeRole.StoreID == storeId && !strings.HasPrefix(storeRole.Role, scopeRolePrefix) {
+			roles = append(roles, Role(storeRole.Role))
+		}
+	}
+
+	return roles
+}
+
+// Can reports whether any of roles grants permission.
+func (p *Policy) Can(roles []Role, permission Permission) bool {
+	for _, role := range roles {
+		if scope, ok := strings.CutPrefix(string(role), scopeRolePrefix); ok {
+			if grants(Permission(scope), permission) {
+				return true
+			}
+			continue
+		}
+
+		if GrantedBy(p.rolePermissions[role], permission) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GrantedBy reports whether any of granted, wildcards included, grants permission.
+// Used for the scopes of API keys.
+func GrantedBy(granted []Permission, permission Permission) bool {
+	for _, g := range granted {
+		if grants(g, permission) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UserCan reports whether user has permission in store.
+func (p *Policy) UserCan(user model.User, storeId primitive.ObjectID, permission Permission) bool {
+	return p.Can(RolesOf(user, storeId), permission)
+}
+
+// Authorize returns entity.Forbidden unless any of roles grants permission.
+// Intended for use in use cases.
+//
+// EXAMPLE:
+//
+//	if err := policy.DefaultPolicy().Authorize(roles, policy.PermissionTransactionDelete); err != nil {
+//		return err
+//	}
+func (p *Policy) Authorize(roles []Role, permission Permission) *entity.HttpError {
+	if !p.Can(roles, permission) {
+		return entity.Forbidden("You do not have permission to " + describe(permission))
+	}
+
+	return nil
+}
+
+// AuthorizeFields checks the permission of every updated field listed in fieldPermissions,
+// e.g. ProductFieldPermissions. Fields not listed are not checked.
+//
+// EXAMPLE:
+//
+//	update := bson.M{"$set": bson.M{"name": body.Name, "capitalPrice": body.CapitalPrice}}
+//	if err := p.Authorize(roles, policy.PermissionProductUpdate); err != nil {
+//		return err
+//	}
+//	if err := p.AuthorizeFields(roles, policy.UpdatedFields(update), policy.ProductFieldPermissions); err != nil {
+//		return err // cashiers cannot edit the capital price
+//	}
+func (p *Policy) AuthorizeFields(
+	roles []Role,
+	fields []string,
+	fieldPermissions map[string]Permission,
+) *entity.HttpError {
+	for _, field := range fields {
+		permission, exist := fieldPermissions[field]
+		if !exist {
+			continue
+		}
+
+		if !p.Can(roles, permission) {
+			return entity.Forbidden("You do not have permission to update " + field)
+		}
+	}
+
+	return nil
+}
+
+// UpdatedFields returns the dotted paths of the non-nil values of an update document.
+// Update operators ($set, $inc, ...) and array indexes are skipped, so
+// {"$set": {"variants": [{"capitalPrice": 1}]}} returns "variants" and "variants.capitalPrice".
+//
+// Operator values are converted like BaseService does, with parser.StructToMap, so structs
+// and DTOs ({"$set": body}) report the fields they will actually write.
+func UpdatedFields(update bson.M) []string {
+	fields := []string{}
+	for key, value := range update {
+		if strings.HasPrefix(key, "$") && value != nil {
+			if converted, err := parser.StructToMap(value); err == nil {
+				value = converted
+			}
+		}
+		collectField(key, value, "", &fields)
+	}
+	return fields
+}
+
+func collectFields(value interface{}, prefix string, fields *[]string) {
+	switch v := value.(type) {
+	case bson.M:
+		for key, nested := range v {
+			collectField(key, nested, prefix, fields)
+		}
+	case map[string]interface{}:
+		collectFields(bson.M(v), prefix, fields)
+	case bson.D:
+		for _, element := range v {
+			collectField(element.Key, element.Value, prefix, fields)
+		}
+	case bson.A:
+		for _, item := range v {
+			collectFields(item, prefix, fields)
+		}
+	case []interface{}:
+		collectFields(bson.A(v), prefix, fields)
+	case []bson.M:
+		for _, item := range v {
+			collectFields(item, prefix, fields)
+		}
+	}
+}
+
+func collectField(key string, value interface{}, prefix string, fields *[]string) {
+	if value == nil {
+		return
+	}
+
+	path := prefix
+	if !strings.HasPrefix(key, "$") {
+		// Drop positional and index segments, e.g. "variants.$.capitalPrice" or "variants.0.capitalPrice".
+		segments := []string{}
+		for _, segment := range strings.Split(key, ".") {
+			if strings.HasPrefix(segment, "$") || strings.Trim(segment, "0123456789") == "" {
+				continue
+			}
+			segments = append(segments, segment)
+		}
+
+		path = strings.Join(segments, ".")
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		*fields = append(*fields, path)
+	}
+
+	collectFields(value, path, fields)
+}
+
+func grants(granted Permission, permission Permission) bool {
+	if granted == PermissionAll || granted == permission {
+		return true
+	}
+
+	resource, found := strings.CutSuffix(string(granted), ":*")
+	return found && strings.HasPrefix(string(permission), resource+":")
+}
+
+// describe turns "product:update" into "update product".
+func describe(permission Permission) string {
+	resource, action, found := strings.Cut(string(permission), ":")
+	if !found {
+		return string(permission)
+	}
+
+	return strings.ReplaceAll(action, "-", " ") + " " + resource
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	// Token bucket.
+	tokens float64
+	at     time.Time
+	// Sliding window.
+	start    time.Time
+	current  int
+	previous int
+
+	expiresAt time.Time
+}
+
+// MemoryStore keeps counters in process. Limits are per instance, use MongoStore
+// when several instances serve the same clients.
+type MemoryStore struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+// NewMemoryStore is a constructor to initialize MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		maxEntries: 100000,
+		entries:    map[string]*memoryEntry{},
+	}
+}
+
+func (s *MemoryStore) Allow(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exist := s.entries[key]
+	if !exist {
+		if len(s.entries) >= s.maxEntries {
+			s.evict(now)
+		}
+		entry = &memoryEntry{tokens: float64(limit.burst()), at: now}
+		s.entries[key] = entry
+	}
+
+	var result Result
+	if limit.algorithm() == AlgorithmTokenBucket {
+		entry.tokens, result = takeToken(entry.tokens, entry.at, limit, now)
+		entry.at = now
+		entry.expiresAt = now.Add(result.Reset)
+	} else {
+		start := windowStart(now, limit.Window)
+		switch {
+		case start.Equal(entry.start):
+		case start.Equal(entry.start.Add(limit.Window)):
+			entry.previous, entry.current = entry.current, 0
+		default:
+			entry.previous, entry.current = 0, 0
+		}
+		entry.start = start
+
+		result = slidingWindowResult(entry.previous, entry.current+1, limit, now)
+		if result.Allowed {
+			entry.current++
+		}
+		entry.expiresAt = start.Add(2 * limit.Window)
+	}
+
+	return result, nil
+}
+
+// evict drops expired entries, or everything if none expired. Must hold s.mu.
+func (s *MemoryStore) evict(now time.Time) {
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+
+	if len(s.entries) >= s.maxEntries {
+		s.entries = map[string]*memoryEntry{}
+	}
+}
@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/susatyo441/go-ta-utils/db"
+	"github.com/susatyo441/go-ta-utils/model"
+	"github.com/susatyo441/go-ta-utils/service"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Attempts of the optimistic token bucket update before giving up.
+const maxTokenBucketAttempts = 5
+
+var errRateLimitContention = errors.New("rate limit counter is too contended")
+
+// MongoStore shares counters between instances through a collection.
+// Call EnsureIndexes once so expired counters are deleted.
+type MongoStore struct {
+	CounterService service.Service[model.RateLimitCounter]
+}
+
+// NewMongoStore is a constructor to initialize MongoStore on the admin database.
+func NewMongoStore() *MongoStore {
+	return &MongoStore{
+		CounterService: service.NewAdminService[model.RateLimitCounter](db.RateLimitModelName),
+	}
+}
+
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	return s.CounterService.SetDeleteFromDatabaseAttribute(ctx, bson.M{"expiresAt": 1})
+}
+
+func (s *MongoStore) Allow(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	if limit.algorithm() == AlgorithmTokenBucket {
+		return s.takeToken(ctx, key, limit, now)
+	}
+
+	return s.slidingWindow(ctx, key, limit, now)
+}
+
+// slidingWindow keeps one counter per fixed window, incremented atomically.
+func (s *MongoStore) slidingWindow(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	start := windowStart(now, limit.Window)
+	id := key + ":" + strconv.FormatInt(start.UnixMilli(), 10)
+	previousId := key + ":" + strconv.FormatInt(start.Add(-limit.Window).UnixMilli(), 10)
+
+	counter, err := s.CounterService.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{
+			"$inc":         bson.M{"count": 1},
+			"$setOnInsert": bson.M{"expiresAt": start.Add(2 * limit.Window)},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	)
+	if err != nil {
+		return Result{}, err
+	}
+
+	previous := 0
+	previousCounter, err := s.CounterService.FindOne(ctx, bson.M{"_id": previousId})
+	if err != nil && err != mongo.ErrNoDocuments {
+		return Result{}, err
+	}
+	if previousCounter != nil {
+		previous = previousCounter.Count
+	}
+
+	result := slidingWindowResult(previous, counter.Count, limit, now)
+	if !result.Allowed {
+		// Rejected requests do not use the quota.
+		if _, err := s.CounterService.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"count": -1}}); err != nil {
+			return Result{}, err
+		}
+	}
+
+	return result, nil
+}
+
+// takeToken updates the bucket with compare-and-swap on its timestamp, retrying on conflicts.
+func (s *MongoStore) takeToken(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	for attempt := 0; attempt < maxTokenBucketAttempts; attempt++ {
+		counter, err := s.CounterService.FindOne(ctx, bson.M{"_id": key})
+		if err != nil && err != mongo.ErrNoDocuments {
+			return Result{}, err
+		}
+
+		if counter == nil {
+			tokens, result := takeToken(float64(limit.burst()), now, limit, now)
+
+			// Insert only if still absent: no document before means this request created the bucket.
+			_, err := s.CounterService.FindOneAndUpdate(
+				ctx,
+				bson.M{"_id": key},
+				bson.M{"$setOnInsert": bson.M{"tokens": tokens, "at": now, "expiresAt": now.Add(result.Reset)}},
+				options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before),
+			)
+			if err == mongo.ErrNoDocuments {
+				return result, nil
+			}
+			if err != nil && !mongo.IsDuplicateKeyError(err) {
+				return Result{}, err
+			}
+			continue
+		}
+
+		// Keep the clock monotonic per key when instances disagree slightly.
+		at := now
+		if at.Before(counter.At) {
+			at = counter.At
+		}
+
+		tokens, result := takeToken(counter.Tokens, counter.At, limit, at)
+		if !result.Allowed {
+			return result, nil
+		}
+
+		updated, err := s.CounterService.UpdateOne(
+			ctx,
+			bson.M{"_id": key, "at": counter.At, "tokens": counter.Tokens},
+			bson.M{"$set": bson.M{"tokens": tokens, "at": at, "expiresAt": at.Add(result.Reset)}},
+		)
+		if err != nil {
+			return Result{}, err
+		}
+		if updated > 0 {
+			return result, nil
+		}
+	}
+
+	return Result{}, errRateLimitContention
+}
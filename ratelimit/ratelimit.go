@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+type Algorithm string
+
+const (
+	// Allows bursts up to Limit.Burst, refilled at Limit.Requests per Limit.Window.
+	AlgorithmTokenBucket Algorithm = "token bucket"
+	// At most Limit.Requests in any Limit.Window, estimated from the current and previous window counts.
+	AlgorithmSlidingWindow Algorithm = "sliding window"
+)
+
+type Limit struct {
+	Requests int
+	Window   time.Duration
+	// Defaults to AlgorithmSlidingWindow.
+	Algorithm Algorithm
+	// Bucket size of AlgorithmTokenBucket. Defaults to Requests.
+	Burst int
+}
+
+func (l Limit) algorithm() Algorithm {
+	if l.Algorithm == "" {
+		return AlgorithmSlidingWindow
+	}
+	return l.Algorithm
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// Result of one request against a limit, used for the RateLimit-* headers.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Time until the limit is fully available again.
+	Reset time.Duration
+	// Time until the next request is allowed, zero when allowed.
+	RetryAfter time.Duration
+}
+
+// Store counts requests per key. Implemented by MemoryStore and MongoStore.
+type Store interface {
+	// Allow counts one request for key at now and reports whether it is within limit.
+	Allow(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// takeToken refills a bucket holding tokens at `at` and takes one token at now.
+func takeToken(tokens float64, at time.Time, limit Limit, now time.Time) (float64, Result) {
+	burst := float64(limit.burst())
+	rate := float64(limit.Requests) / limit.Window.Seconds()
+
+	if elapsed := now.Sub(at).Seconds(); elapsed > 0 {
+		tokens = math.Min(burst, tokens+elapsed*rate)
+	}
+
+	result := Result{Limit: limit.burst()}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+
+	result.Remaining = int(math.Floor(tokens))
+	result.Reset = secondsToDuration((burst - tokens) / rate)
+
+	return tokens, result
+}
+
+// windowStart returns the start of the fixed window containing now.
+func windowStart(now time.Time, window time.Duration) time.Time {
+	return now.Truncate(window)
+}
+
+// slidingWindowResult weighs the previous window by how much of it still overlaps
+// the sliding window ending at now. current includes the request being checked.
+func slidingWindowResult(previous int, current int, limit Limit, now time.Time) Result {
+	start := windowStart(now, limit.Window)
+	overlap := 1 - float64(now.Sub(start))/float64(limit.Window)
+	estimated := float64(previous)*overlap + float64(current)
+
+	result := Result{
+		Allowed: estimated <= float64(limit.Requests),
+		Limit:   limit.Requests,
+		Reset:   start.Add(limit.Window).Sub(now),
+	}
+	result.Remaining = max(0, limit.Requests-int(math.Ceil(estimated)))
+
+	if !result.Allowed {
+		result.RetryAfter = result.Reset
+		if previous > 0 {
+			// The previous window weight drops linearly, find when the estimate fits again.
+			excess := estimated - float64(limit.Requests)
+			wait := time.Duration(excess / float64(previous) * float64(limit.Window))
+			result.RetryAfter = min(wait, result.Reset)
+		}
+	}
+
+	return result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
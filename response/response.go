@@ -41,3 +41,7 @@ func NotFound(c *fiber.Ctx, message string, data interface{}) error {
 func InternalServerError(c *fiber.Ctx, message string, data interface{}) error {
 	return SendResponse(c, fiber.StatusInternalServerError, data, message)
 }
+
+func TooManyRequests(c *fiber.Ctx, message string, data interface{}) error {
+	return SendResponse(c, fiber.StatusTooManyRequests, data, message)
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/susatyo441/go-ta-utils/db"
+	"github.com/susatyo441/go-ta-utils/entity"
+	"github.com/susatyo441/go-ta-utils/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// API keys are "ta_<8 hex chars>_<random secret>", "ta_<8 hex chars>" is the stored prefix.
+const apiKeyPrefix = "ta_"
+
+type IAPIKeyUseCase interface {
+	// Create a key and return it with the full key, which is only available here.
+	CreateAPIKey(
+		ctx context.Context,
+		data CreateAPIKeyData,
+	) (*model.APIKey, string, error)
+	// Return the active key matching the full key, and record its use.
+	// Satisfies middleware.APIKeyAuthenticator.
+	AuthenticateAPIKey(
+		ctx context.Context,
+		key string,
+		ip string,
+	) (*model.APIKey, *entity.HttpError)
+	// Keys of a store, newest first, revoked ones included.
+	ListAPIKeys(
+		ctx context.Context,
+		storeId primitive.ObjectID,
+	) ([]model.APIKey, error)
+	RevokeAPIKey(
+		ctx context.Context,
+		storeId primitive.ObjectID,
+		keyId primitive.ObjectID,
+	) *entity.HttpError
+	// Create the indexes of the API keys collection.
+	EnsureAPIKeyIndexes(ctx context.Context) error
+}
+
+type APIKeyUseCaseOptions struct {
+	// Minimum time between two last-used updates of a key, to avoid a write per request.
+	// Defaults to 1 minute.
+	LastUsedInterval time.Duration
+}
+
+type CreateAPIKeyData struct {
+	StoreID primitive.ObjectID
+	UserID  primitive.ObjectID
+	Name    string
+	Scopes  []string
+	// Never expires when nil.
+	ExpiresAt *time.Time
+}
+
+type APIKeyUseCase struct {
+	APIKeyService Service[model.APIKey]
+	Options       APIKeyUseCaseOptions
+}
+
+func determineAPIKeyOptions(opts ...APIKeyUseCaseOptions) APIKeyUseCaseOptions {
+	actualOpts := APIKeyUseCaseOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.LastUsedInterval == 0 {
+		actualOpts.LastUsedInterval = time.Minute
+	}
+
+	return actualOpts
+}
+
+func NewCompanyAPIKeyUseCase(companyCode string, opts ...APIKeyUseCaseOptions) IAPIKeyUseCase {
+	return &APIKeyUseCase{
+		APIKeyService: NewCompanyService[model.APIKey](companyCode, db.APIKeyModelName),
+		Options:       determineAPIKeyOptions(opts...),
+	}
+}
+
+func NewAdminAPIKeyUseCase(opts ...APIKeyUseCaseOptions) IAPIKeyUseCase {
+	return &APIKeyUseCase{
+		APIKeyService: NewAdminService[model.APIKey](db.APIKeyModelName),
+		Options:       determineAPIKeyOptions(opts...),
+	}
+}
+
+func (u *APIKeyUseCase) CreateAPIKey(
+	ctx context.Context,
+	data CreateAPIKeyData,
+) (*model.APIKey, string, error) {
+	prefix, secret, err := newAPIKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	scopes := data.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	apiKey := model.APIKey{
+		ID:        primitive.NewObjectID(),
+		StoreID:   data.StoreID,
+		UserID:    data.UserID,
+		Name:      data.Name,
+		Prefix:    prefix,
+		KeyHash:   hashSecret(secret),
+		Scopes:    scopes,
+		ExpiresAt: data.ExpiresAt,
+	}
+
+	if _, err := u.APIKeyService.InsertOne(ctx, apiKey); err != nil {
+		return nil, "", err
+	}
+
+	return &apiKey, prefix + "_" + secret, nil
+}
+
+func (u *APIKeyUseCase) AuthenticateAPIKey(
+	ctx context.Context,
+	key string,
+	ip string,
+) (*model.APIKey, *entity.HttpError) {
+	prefix, secret, ok := parseAPIKey(key)
+	if !ok {
+		return nil, entity.Unauthorized("Invalid API key")
+	}
+
+	apiKey, err := u.APIKeyService.FindOne(ctx, bson.M{"prefix": prefix})
+	if err == mongo.ErrNoDocuments {
+		return nil, entity.Unauthorized("Invalid API key")
+	}
+	if err != nil {
+		return nil, entity.InternalServerError(err.Error())
+	}
+
+	if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(hashSecret(secret))) != 1 {
+		return nil, entity.Unauthorized("Invalid API key")
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil {
+		return nil, entity.Unauthorized("API key revoked")
+	}
+	if apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(now) {
+		return nil, entity.Unauthorized("API key expired")
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= u.Options.LastUsedInterval {
+		set := bson.M{"lastUsedAt": now}
+		if ip != "" {
+			set["lastUsedIp"] = ip
+		}
+
+		// Tracking is best effort, a failed write must not reject the request.
+		_, _ = u.APIKeyService.UpdateOne(ctx, bson.M{"_id": apiKey.ID}, bson.M{"$set": set})
+		apiKey.LastUsedAt = &now
+		if ip != "" {
+			apiKey.LastUsedIP = &ip
+		}
+	}
+
+	return apiKey, nil
+}
+
+func (u *APIKeyUseCase) ListAPIKeys(
+	ctx context.Context,
+	storeId primitive.ObjectID,
+) ([]model.APIKey, error) {
+	return u.APIKeyService.Find(
+		ctx,
+		bson.M{"storeId": storeId},
+		options.Find().SetSort(bson.M{"_id": -1}),
+	)
+}
+
+func (u *APIKeyUseCase) RevokeAPIKey(
+	ctx context.Context,
+	storeId primitive.ObjectID,
+	keyId primitive.ObjectID,
+) *entity.HttpError {
+	count, err := u.APIKeyService.UpdateOne(
+		ctx,
+		bson.M{"_id": keyId, "storeId": storeId, "revokedAt": nil},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err != nil {
+		return entity.InternalServerError(err.Error())
+	}
+	if count == 0 {
+		return entity.NotFound("API key not found")
+	}
+
+	return nil
+}
+
+func (u *APIKeyUseCase) EnsureAPIKeyIndexes(ctx context.Context) error {
+	if err := u.APIKeyService.MakeUnique(ctx, bson.M{"prefix": 1}); err != nil {
+		return err
+	}
+
+	return u.APIKeyService.CreateIndex(ctx, bson.M{"storeId": 1})
+}
+
+func newAPIKeySecret() (string, string, error) {
+	id := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", errors.New("failed to generate API key")
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", errors.New("failed to generate API key")
+	}
+
+	return apiKeyPrefix + hex.EncodeToString(id), base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func parseAPIKey(key string) (string, string, bool) {
+	rest, found := strings.CutPrefix(key, apiKeyPrefix)
+	if !found {
+		return "", "", false
+	}
+
+	id, secret, found := strings.Cut(rest, "_")
+	if !found || len(id) != 8 || secret == "" {
+		return "", "", false
+	}
+
+	return apiKeyPrefix + id, secret, true
+}
+
+type MockAPIKeyUseCase struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyUseCase) CreateAPIKey(
+	ctx context.Context,
+	data CreateAPIKeyData,
+) (*model.APIKey, string, error) {
+	args := m.Called(ctx, data)
+	return args.Get(0).(*model.APIKey), args.String(1), args.Error(2)
+}
+
+func (m *MockAPIKeyUseCase) AuthenticateAPIKey(
+	ctx context.Context,
+	key string,
+	ip string,
+) (*model.APIKey, *entity.HttpError) {
+	args := m.Called(ctx, key, ip)
+	return args.Get(0).(*model.APIKey), args.Get(1).(*entity.HttpError)
+}
+
+func (m *MockAPIKeyUseCase) ListAPIKeys(
+	ctx context.Context,
+	storeId primitive.ObjectID,
+) ([]model.APIKey, error) {
+	args := m.Called(ctx, storeId)
+	return args.Get(0).([]model.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyUseCase) RevokeAPIKey(
+	ctx context.Context,
+	storeId primitive.ObjectID,
+	keyId primitive.ObjectID,
+) *entity.HttpError {
+	args := m.Called(ctx, storeId, keyId)
+	return args.Get(0).(*entity.HttpError)
+}
+
+func (m *MockAPIKeyUseCase) EnsureAPIKeyIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
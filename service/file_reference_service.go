@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/susatyo441/go-ta-utils/db"
+	"github.com/susatyo441/go-ta-utils/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IFileReferenceUseCase counts references to stored files, so deduplicated images are
+// only deleted once no document uses them. Satisfies storage.ReferenceCounter.
+//
+// EXAMPLE:
+//
+//	storage.SetReferenceCounter(service.NewAdminFileReferenceUseCase())
+type IFileReferenceUseCase interface {
+	// Add a reference to key and return the new count.
+	Retain(ctx context.Context, key string) (int, error)
+	// Remove a reference to key and return the remaining count, 0 when the file can be deleted.
+	Release(ctx context.Context, key string) (int, error)
+	// Drop the count of key unless it is referenced, reporting whether the file can be deleted.
+	Forget(ctx context.Context, key string) (bool, error)
+}
+
+type FileReferenceUseCase struct {
+	FileReferenceService Service[model.FileReference]
+}
+
+func NewCompanyFileReferenceUseCase(companyCode string) IFileReferenceUseCase {
+	return &FileReferenceUseCase{
+		FileReferenceService: NewCompanyService[model.FileReference](companyCode, db.FileReferenceModelName),
+	}
+}
+
+func NewAdminFileReferenceUseCase() IFileReferenceUseCase {
+	return &FileReferenceUseCase{
+		FileReferenceService: NewAdminService[model.FileReference](db.FileReferenceModelName),
+	}
+}
+
+func (u *FileReferenceUseCase) Retain(ctx context.Context, key string) (int, error) {
+	reference, err := u.FileReferenceService.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$inc":         bson.M{"count": 1},
+			"$setOnInsert": bson.M{"createdAt": time.Now()},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		// Lost a concurrent upsert race, the document exists now.
+		return u.Retain(ctx, key)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return reference.Count, nil
+}
+
+func (u *FileReferenceUseCase) Release(ctx context.Context, key string) (int, error) {
+	reference, err := u.FileReferenceService.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": key, "count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"count": -1}},
+	)
+	if err == mongo.ErrNoDocuments {
+		// Never counted, e.g. saved before reference counting was enabled.
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if reference.Count > 0 {
+		return reference.Count, nil
+	}
+
+	deleted, err := u.FileReferenceService.DeleteOne(ctx, bson.M{"_id": key, "count": bson.M{"$lte": 0}})
+	if err != nil {
+		return 0, err
+	}
+	if deleted == 0 {
+		// Retained again meanwhile, the file is in use.
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+func (u *FileReferenceUseCase) Forget(ctx context.Context, key string) (bool, error) {
+	if _, err := u.FileReferenceService.DeleteOne(ctx, bson.M{"_id": key, "count": bson.M{"$lte": 0}}); err != nil {
+		return false, err
+	}
+
+	// Still there when referenced.
+	count, err := u.FileReferenceService.CountDocuments(ctx, bson.M{"_id": key})
+	if err != nil {
+		return false, err
+	}
+
+	return count == 0, nil
+}
+
+type MockFileReferenceUseCase struct {
+	mock.Mock
+}
+
+func (m *MockFileReferenceUseCase) Retain(ctx context.Context, key string) (int, error) {
+	args := m.Called(ctx, key)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockFileReferenceUseCase) Release(ctx context.Context, key string) (int, error) {
+	args := m.Called(ctx, key)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockFileReferenceUseCase) Forget(ctx context.Context, key string) (bool, error) {
+	args := m.Called(ctx, key)
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/susatyo441/go-ta-utils/db"
+	"github.com/susatyo441/go-ta-utils/logger"
+	"github.com/susatyo441/go-ta-utils/model"
+	"github.com/susatyo441/go-ta-utils/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IFileSweeperUseCase finds the files of storage.Default() referenced by no document.
+//
+// EXAMPLE:
+//
+//	sweeper := service.NewCompanyFileSweeperUseCase(companyCode)
+//	report, err := sweeper.SweepOrphanFiles(ctx)
+//	for _, file := range report.Orphans {
+//		fmt.Println(file.Key, file.Size)
+//	}
+//	// once the report is checked
+//	report, err = sweeper.SweepOrphanFiles(ctx, service.SweepOrphanFilesOptions{Delete: true})
+type IFileSweeperUseCase interface {
+	// Scan the files under the prefixes and report those referenced by no product, product photo,
+	// store, user or unfinished image job. Only deletes them with SweepOrphanFilesOptions.Delete.
+	SweepOrphanFiles(ctx context.Context, opts ...SweepOrphanFilesOptions) (*SweepReport, error)
+}
+
+type SweepOrphanFilesOptions struct {
+	// Key prefixes scanned. Defaults to "<storeId>/" of each store of the database,
+	// files outside of them (of another company) are never touched.
+	Prefixes []string
+	// Delete the orphans, which are only reported otherwise. Orphans still counted as referenced
+	// (see storage.SetReferenceCounter) are kept.
+	Delete bool
+	// Files modified more recently are kept, as their document may not be saved yet. Defaults to 24 hours.
+	MinAge time.Duration
+}
+
+type SweepReport struct {
+	// Files scanned.
+	Scanned int
+	// Files referenced by no document, deleted with Delete.
+	Orphans []storage.ObjectInfo
+	// Orphans deleted.
+	Deleted int
+	// Orphans kept with Delete, as their reference count is live (e.g. reused by an upload meanwhile).
+	Kept int
+	// Bytes of the orphans.
+	OrphanBytes int64
+}
+
+type FileSweeperUseCase struct {
+	ProductService      Service[model.Product]
+	ProductPhotoService Service[model.ProductPhoto]
+	StoreService        Service[model.Store]
+	UserService         Service[model.User]
+	ImageJobService     Service[model.ImageJob]
+	// Defaults to storage.Default().
+	Backend storage.Backend
+}
+
+func NewCompanyFileSweeperUseCase(companyCode string) IFileSweeperUseCase {
+	return &FileSweeperUseCase{
+		ProductService:      NewCompanyService[model.Product](companyCode, db.ProductModelName),
+		ProductPhotoService: NewCompanyService[model.ProductPhoto](companyCode, db.ProductPhotoModelName),
+		StoreService:        NewCompanyService[model.Store](companyCode, db.StoreModelName),
+		UserService:         NewCompanyService[model.User](companyCode, db.UserModelName),
+		ImageJobService:     NewCompanyService[model.ImageJob](companyCode, db.ImageJobModelName),
+		Backend:             storage.Default(),
+	}
+}
+
+func NewAdminFileSweeperUseCase() IFileSweeperUseCase {
+	return &FileSweeperUseCase{
+		ProductService:      NewAdminService[model.Product](db.ProductModelName),
+		ProductPhotoService: NewAdminService[model.ProductPhoto](db.ProductPhotoModelName),
+		StoreService:        NewAdminService[model.Store](db.StoreModelName),
+		UserService:         NewAdminService[model.User](db.UserModelName),
+		ImageJobService:     NewAdminService[model.ImageJob](db.ImageJobModelName),
+		Backend:             storage.Default(),
+	}
+}
+
+func (u *FileSweeperUseCase) SweepOrphanFiles(ctx context.Context, opts ...SweepOrphanFilesOptions) (*SweepReport, error) {
+	actualOpts := SweepOrphanFilesOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+	if actualOpts.MinAge == 0 {
+		actualOpts.MinAge = 24 * time.Hour
+	}
+
+	backend := u.Backend
+	if backend == nil {
+		backend = storage.Default()
+	}
+	lister, ok := backend.(storage.Lister)
+	if !ok {
+		return nil, errors.New("storage backend cannot list its files")
+	}
+
+	references, stores, err := u.fileReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := actualOpts.Prefixes
+	if len(prefixes) == 0 {
+		for _, storeId := range stores {
+			prefixes = append(prefixes, storeId.Hex()+"/")
+		}
+	}
+
+	report := &SweepReport{Orphans: []storage.ObjectInfo{}}
+	cutoff := time.Now().Add(-actualOpts.MinAge)
+
+	for _, prefix := range prefixes {
+		// An empty prefix would scan the files of every company.
+		if strings.Trim(prefix, "/") == "" {
+			return nil, errors.New("sweep prefixes cannot be empty")
+		}
+
+		err := lister.List(ctx, strings.TrimPrefix(prefix, "/"), func(file storage.ObjectInfo) error {
+			report.Scanned++
+
+			if references[file.Key] || file.ModTime.After(cutoff) {
+				return nil
+			}
+
+			report.Orphans = append(report.Orphans, file)
+			report.OrphanBytes += file.Size
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !actualOpts.Delete {
+		return report, nil
+	}
+
+	for _, file := range report.Orphans {
+		// Clears the count of the file, so a later upload of the same content writes it again.
+		unreferenced, err := storage.Forget(ctx, file.Key)
+		if err != nil {
+			logger.FromContext(ctx).Warn("Failed to check orphan file references", "error", err, "key", file.Key)
+			continue
+		}
+		if !unreferenced {
+			report.Kept++
+			continue
+		}
+
+		err = backend.Delete(ctx, file.Key)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			logger.FromContext(ctx).Warn("Failed to delete orphan file", "error", err, "key", file.Key)
+			continue
+		}
+		report.Deleted++
+	}
+
+	logger.FromContext(ctx).Info(
+		"Orphan files swept",
+		"scanned", report.Scanned,
+		"orphans", len(report.Orphans),
+		"deleted", report.Deleted,
+		"kept", report.Kept,
+	)
+
+	return report, nil
+}
+
+// fileReferences returns the storage keys referenced by documents, and the ids of the stores.
+//
+// Stored paths are either keys or relative to their store ("/product/a.jpg" for the key
+// "<storeId>/product/a.jpg"), both are counted as referenced.
+func (u *FileSweeperUseCase) fileReferences(ctx context.Context) (map[string]bool, []primitive.ObjectID, error) {
+	references := map[string]bool{}
+	add := func(storeId *primitive.ObjectID, paths ...*string) {
+		for _, p := range paths {
+			if p == nil || *p == "" {
+				continue
+			}
+			if key, err := storage.CleanKey(*p); err == nil {
+				references[key] = true
+			}
+			if storeId != nil && !storeId.IsZero() {
+				if key, err := storage.CleanKey(path.Join(storeId.Hex(), *p)); err == nil {
+					references[key] = true
+				}
+			}
+		}
+	}
+
+	stores, err := u.StoreService.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{
+		"logoSmall": 1, "logoMedium": 1, "logoBig": 1, "photoModel": 1, "forecastModel": 1,
+	}))
+	if err != nil {
+		return nil, nil, err
+	}
+	storeIds := make([]primitive.ObjectID, 0, len(stores))
+	for _, store := range stores {
+		storeIds = append(storeIds, store.ID)
+		add(&store.ID, store.LogoSmall, store.LogoMedium, store.LogoBig, store.PhotoModel, store.ForecastModel)
+	}
+
+	products, err := u.ProductService.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{
+		"storeId": 1, "coverPhoto": 1,
+	}))
+	if err != nil {
+		return nil, nil, err
+	}
+	productStores := make(map[primitive.ObjectID]primitive.ObjectID, len(products))
+	for _, product := range products {
+		productStores[product.ID] = product.StoreID
+		add(&product.StoreID, &product.CoverPhoto)
+	}
+
+	photos, err := u.ProductPhotoService.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{
+		"productId": 1, "photo": 1,
+	}))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, photo := range photos {
+		storeId := productStores[photo.ProductID]
+		add(&storeId, &photo.Photo)
+	}
+
+	users, err := u.UserService.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{
+		"store": 1, "profilePictureSmall": 1, "profilePictureMedium": 1, "profilePictureBig": 1,
+	}))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, user := range users {
+		add(user.Store.ID, user.ProfilePictureSmall, user.ProfilePictureMedium, user.ProfilePictureBig)
+	}
+
+	// The originals of unfinished jobs are still stored as placeholders.
+	jobs, err := u.ImageJobService.Find(
+		ctx,
+		bson.M{"status": bson.M{"$in": bson.A{model.ImageJobPending, model.ImageJobProcessing}}},
+		options.Find().SetProjection(bson.M{"sourceKey": 1}),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, job := range jobs {
+		add(nil, &job.SourceKey)
+	}
+
+	return references, storeIds, nil
+}
+
+type MockFileSweeperUseCase struct {
+	mock.Mock
+}
+
+func (m *MockFileSweeperUseCase) SweepOrphanFiles(ctx context.Context, opts ...SweepOrphanFilesOptions) (*SweepReport, error) {
+	args := m.Called(ctx, opts)
+	return args.Get(0).(*SweepReport), args.Error(1)
+}
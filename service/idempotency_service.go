@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/susatyo441/go-ta-utils/db"
+	"github.com/susatyo441/go-ta-utils/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IIdempotencyUseCase interface {
+	// Claim the key for a request. Returns nil when the caller must process the request,
+	// otherwise the record of the earlier request with this key (processing or completed).
+	// Satisfies middleware.IdempotencyStore with the two methods below.
+	BeginIdempotentRequest(
+		ctx context.Context,
+		scope string,
+		key string,
+		fingerprint string,
+	) (*model.IdempotencyRecord, error)
+	// Save the response to replay on retries.
+	CompleteIdempotentRequest(
+		ctx context.Context,
+		scope string,
+		key string,
+		response model.IdempotencyResponse,
+	) error
+	// Forget the key after a failed request, so it can be retried.
+	ReleaseIdempotentRequest(
+		ctx context.Context,
+		scope string,
+		key string,
+	) error
+	// Create the TTL index of the idempotency collection.
+	EnsureIdempotencyIndexes(ctx context.Context) error
+}
+
+type IdempotencyUseCaseOptions struct {
+	// How long responses are replayed. Defaults to 24 hours.
+	TTL time.Duration
+	// A request still processing after this long is considered crashed, and its key can be claimed again.
+	// Defaults to 1 minute.
+	ProcessingTimeout time.Duration
+}
+
+type IdempotencyUseCase struct {
+	IdempotencyService Service[model.IdempotencyRecord]
+	Options            IdempotencyUseCaseOptions
+}
+
+func determineIdempotencyOptions(opts ...IdempotencyUseCaseOptions) IdempotencyUseCaseOptions {
+	actualOpts := IdempotencyUseCaseOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.TTL == 0 {
+		actualOpts.TTL = 24 * time.Hour
+	}
+	if actualOpts.ProcessingTimeout == 0 {
+		actualOpts.ProcessingTimeout = time.Minute
+	}
+
+	return actualOpts
+}
+
+func NewCompanyIdempotencyUseCase(companyCode string, opts ...IdempotencyUseCaseOptions) IIdempotencyUseCase {
+	return &IdempotencyUseCase{
+		IdempotencyService: NewCompanyService[model.IdempotencyRecord](companyCode, db.IdempotencyModelName),
+		Options:            determineIdempotencyOptions(opts...),
+	}
+}
+
+func NewAdminIdempotencyUseCase(opts ...IdempotencyUseCaseOptions) IIdempotencyUseCase {
+	return &IdempotencyUseCase{
+		IdempotencyService: NewAdminService[model.IdempotencyRecord](db.IdempotencyModelName),
+		Options:            determineIdempotencyOptions(opts...),
+	}
+}
+
+func (u *IdempotencyUseCase) BeginIdempotentRequest(
+	ctx context.Context,
+	scope string,
+	key string,
+	fingerprint string,
+) (*model.IdempotencyRecord, error) {
+	now := time.Now()
+	id := idempotencyId(scope, key)
+
+	// Insert only if absent: no document before means this request claimed the key.
+	existing, err := u.IdempotencyService.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$setOnInsert": bson.M{
+			"key":         key,
+			"fingerprint": fingerprint,
+			"status":      model.IdempotencyProcessing,
+			"response":    nil,
+			"lockedAt":    now,
+			"expiresAt":   now.Add(u.Options.TTL),
+			"createdAt":   now,
+		}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before),
+	)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		// Lost a concurrent upsert race, read the winner.
+		return u.IdempotencyService.FindOne(ctx, bson.M{"_id": id})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if existing.Status == model.IdempotencyProcessing &&
+		existing.Fingerprint == fingerprint &&
+		now.Sub(existing.LockedAt) > u.Options.ProcessingTimeout {
+		// The first attempt crashed, take over the key.
+		count, err := u.IdempotencyService.UpdateOne(
+			ctx,
+			bson.M{"_id": id, "status": model.IdempotencyProcessing, "lockedAt": existing.LockedAt},
+			bson.M{"$set": bson.M{"lockedAt": now, "expiresAt": now.Add(u.Options.TTL)}},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, nil
+		}
+	}
+
+	return existing, nil
+}
+
+func (u *IdempotencyUseCase) CompleteIdempotentRequest(
+	ctx context.Context,
+	scope string,
+	key string,
+	response model.IdempotencyResponse,
+) error {
+	_, err := u.IdempotencyService.UpdateOne(
+		ctx,
+		bson.M{"_id": idempotencyId(scope, key)},
+		bson.M{"$set": bson.M{
+			"status":    model.IdempotencyCompleted,
+			"response":  response,
+			"expiresAt": time.Now().Add(u.Options.TTL),
+		}},
+	)
+
+	return err
+}
+
+func (u *IdempotencyUseCase) ReleaseIdempotentRequest(
+	ctx context.Context,
+	scope string,
+	key string,
+) error {
+	_, err := u.IdempotencyService.DeleteOne(ctx, bson.M{
+		"_id":    idempotencyId(scope, key),
+		"status": model.IdempotencyProcessing,
+	})
+
+	return err
+}
+
+func (u *IdempotencyUseCase) EnsureIdempotencyIndexes(ctx context.Context) error {
+	return u.IdempotencyService.SetDeleteFromDatabaseAttribute(ctx, bson.M{"expiresAt": 1})
+}
+
+func idempotencyId(scope string, key string) string {
+	return scope + ":" + key
+}
+
+type MockIdempotencyUseCase struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyUseCase) BeginIdempotentRequest(
+	ctx context.Context,
+	scope string,
+	key string,
+	fingerprint string,
+) (*model.IdempotencyRecord, error) {
+	args := m.Called(ctx, scope, key, fingerprint)
+	return args.Get(0).(*model.IdempotencyRecord), args.Error(1)
+}
+
+func (m *MockIdempotencyUseCase) CompleteIdempotentRequest(
+	ctx context.Context,
+	scope string,
+	key string,
+	response model.IdempotencyResponse,
+) error {
+	args := m.Called(ctx, scope, key, response)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyUseCase) ReleaseIdempotentRequest(
+	ctx context.Context,
+	scope string,
+	key string,
+) error {
+	args := m.Called(ctx, scope, key)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyUseCase) EnsureIdempotencyIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
@@ -0,0 +1,555 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/susatyo441/go-ta-utils/db"
+	"github.com/susatyo441/go-ta-utils/logger"
+	"github.com/susatyo441/go-ta-utils/model"
+	"github.com/susatyo441/go-ta-utils/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IImageJobUseCase resizes uploaded images in the background, outside of the request.
+//
+// EXAMPLE:
+//
+//	images := service.NewAdminImageJobUseCase()
+//	go images.RunImageWorkers(ctx)
+//
+//	// in handler
+//	img, mimeType, err := storage.DecodeUpload(fileHeader)
+//	photoId := primitive.NewObjectID()
+//	placeholder, _, err := images.EnqueueImageJob(ctx, service.EnqueueImageJobData{
+//		CompanyCode: companyCode,
+//		Collection:  db.ProductPhotoModelName,
+//		DocumentID:  photoId,
+//		Fields:      map[string]string{"medium": "photo"},
+//		Dir:         storeId.Hex() + "/product",
+//		PathPrefix:  storeId.Hex(),
+//		Image:       img,
+//		SourceType:  mimeType,
+//		Variants:    []storage.Variant{storage.VariantMedium},
+//	})
+//	photoService.InsertOne(ctx, model.ProductPhoto{ID: photoId, Photo: placeholder, ...})
+type IImageJobUseCase interface {
+	// Save the original image and queue the generation of its variants.
+	// Returns the path of the original, to store in the fields until the job replaces it.
+	EnqueueImageJob(ctx context.Context, data EnqueueImageJobData) (string, *model.ImageJob, error)
+	// Get a job, to follow its status.
+	GetImageJob(ctx context.Context, jobId primitive.ObjectID) (*model.ImageJob, error)
+	// Process jobs until ctx is canceled, then wait for the running jobs.
+	// Also runs the jobs left pending or interrupted by a restart.
+	RunImageWorkers(ctx context.Context)
+	// Create the indexes of the job collection.
+	EnsureImageJobIndexes(ctx context.Context) error
+}
+
+type EnqueueImageJobData struct {
+	// Owning document: database (admin database when empty), collection and id.
+	// The document may be inserted after EnqueueImageJob (it needs the returned placeholder):
+	// the job waits for it up to ImageJobUseCaseOptions.DocumentTimeout without using its attempts.
+	CompanyCode string
+	Collection  string
+	DocumentID  primitive.ObjectID
+	// Field of the document set to the path of each variant, by variant name.
+	Fields map[string]string
+	// Storage directory of the images, and the prefix of their keys left out of the stored paths
+	// (e.g. the store id, for paths relative to the store).
+	Dir        string
+	PathPrefix string
+	Image      image.Image
+	// MIME type of the upload, as returned by storage.DecodeUpload.
+	SourceType string
+	// Defaults to storage.DefaultVariants.
+	Variants []storage.Variant
+}
+
+type ImageJobUseCaseOptions struct {
+	// Jobs processed at the same time. Defaults to 2.
+	Workers int
+	// Jobs waiting in memory, the others wait in the collection. Defaults to 100.
+	QueueSize int
+	// Attempts before a job fails. Defaults to 3.
+	MaxAttempts int
+	// Delay before the first retry, doubled on each attempt. Defaults to 10 seconds.
+	RetryDelay time.Duration
+	// How often the collection is polled for pending jobs. Defaults to 30 seconds.
+	PollInterval time.Duration
+	// A job still processing after this long is considered crashed and run again. Defaults to 5 minutes.
+	ProcessingTimeout time.Duration
+	// How long done and failed jobs are kept. Defaults to 7 days.
+	Retention time.Duration
+	// How long after EnqueueImageJob a missing owning document is waited for, retrying every RetryDelay
+	// without counting attempts. Later, a missing document fails the attempt. Defaults to 1 minute.
+	DocumentTimeout time.Duration
+	// Defaults to storage.Default().
+	Backend storage.Backend
+}
+
+type ImageJobUseCase struct {
+	ImageJobService Service[model.ImageJob]
+	// Service of the owning documents of jobs, called by the workers.
+	// Defaults to a company (or admin) service that does not switch the database of the db package.
+	TargetService func(companyCode string, collection string) Service[bson.M]
+	Options       ImageJobUseCaseOptions
+
+	queue chan primitive.ObjectID
+}
+
+func determineImageJobOptions(opts ...ImageJobUseCaseOptions) ImageJobUseCaseOptions {
+	actualOpts := ImageJobUseCaseOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.Workers <= 0 {
+		actualOpts.Workers = 2
+	}
+	if actualOpts.QueueSize <= 0 {
+		actualOpts.QueueSize = 100
+	}
+	if actualOpts.MaxAttempts <= 0 {
+		actualOpts.MaxAttempts = 3
+	}
+	if actualOpts.RetryDelay == 0 {
+		actualOpts.RetryDelay = 10 * time.Second
+	}
+	if actualOpts.PollInterval == 0 {
+		actualOpts.PollInterval = 30 * time.Second
+	}
+	if actualOpts.ProcessingTimeout == 0 {
+		actualOpts.ProcessingTimeout = 5 * time.Minute
+	}
+	if actualOpts.Retention == 0 {
+		actualOpts.Retention = 7 * 24 * time.Hour
+	}
+	if actualOpts.DocumentTimeout == 0 {
+		actualOpts.DocumentTimeout = time.Minute
+	}
+	if actualOpts.Backend == nil {
+		actualOpts.Backend = storage.Default()
+	}
+
+	return actualOpts
+}
+
+func newImageJobUseCase(jobService Service[model.ImageJob], opts ...ImageJobUseCaseOptions) *ImageJobUseCase {
+	actualOpts := determineImageJobOptions(opts...)
+
+	return &ImageJobUseCase{
+		ImageJobService: jobService,
+		TargetService:   imageJobTargetService,
+		Options:         actualOpts,
+		queue:           make(chan primitive.ObjectID, actualOpts.QueueSize),
+	}
+}
+
+func NewCompanyImageJobUseCase(companyCode string, opts ...ImageJobUseCaseOptions) IImageJobUseCase {
+	return newImageJobUseCase(NewCompanyService[model.ImageJob](companyCode, db.ImageJobModelName), opts...)
+}
+
+func NewAdminImageJobUseCase(opts ...ImageJobUseCaseOptions) IImageJobUseCase {
+	return newImageJobUseCase(NewAdminService[model.ImageJob](db.ImageJobModelName), opts...)
+}
+
+// imageJobTargetService runs alongside the requests, so it must not switch the database of the db package.
+func imageJobTargetService(companyCode string, collection string) Service[bson.M] {
+	if companyCode == "" {
+		return newDatabaseService[bson.M](db.AdminDbName, collection)
+	}
+
+	return newDatabaseService[bson.M](db.CompanyDbName(companyCode), collection)
+}
+
+var errImageJobDocumentMissing = errors.New("owning document not found")
+
+func (u *ImageJobUseCase) EnqueueImageJob(ctx context.Context, data EnqueueImageJobData) (string, *model.ImageJob, error) {
+	if len(data.Fields) == 0 {
+		return "", nil, errors.New("image job has no fields to update")
+	}
+
+	variants := data.Variants
+	if len(variants) == 0 {
+		variants = storage.DefaultVariants
+	}
+
+	sourceKey, err := storage.SaveImage(ctx, u.Options.Backend, data.Dir, data.Image, storage.SaveImageOptions{
+		Format:     storage.FormatOriginal,
+		SourceType: data.SourceType,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// One reference per field holding the placeholder, released as the fields are replaced.
+	for i := 1; i < len(data.Fields); i++ {
+		if err := storage.Retain(ctx, sourceKey); err != nil {
+			return "", nil, err
+		}
+	}
+
+	job := model.ImageJob{
+		ID:          primitive.NewObjectID(),
+		CompanyCode: data.CompanyCode,
+		Collection:  data.Collection,
+		DocumentID:  data.DocumentID,
+		Fields:      data.Fields,
+		Dir:         data.Dir,
+		PathPrefix:  data.PathPrefix,
+		SourceKey:   sourceKey,
+		SourceType:  data.SourceType,
+		Placeholder: imageJobPath(sourceKey, data.PathPrefix),
+		Variants:    make([]model.ImageJobVariant, 0, len(variants)),
+		Status:      model.ImageJobPending,
+		RunAfter:    time.Now(),
+	}
+	for _, variant := range variants {
+		job.Variants = append(job.Variants, model.ImageJobVariant{
+			Name:    variant.Name,
+			Width:   variant.Width,
+			Height:  variant.Height,
+			Mode:    string(variant.Mode),
+			Format:  string(variant.Format),
+			Quality: variant.Quality,
+		})
+	}
+
+	if _, err := u.ImageJobService.InsertOne(ctx, job); err != nil {
+		for range data.Fields {
+			_, _ = storage.Release(ctx, u.Options.Backend, sourceKey)
+		}
+		return "", nil, err
+	}
+
+	// Otherwise the queue is full, the job is picked up by the next poll.
+	select {
+	case u.queue <- job.ID:
+	default:
+	}
+
+	return job.Placeholder, &job, nil
+}
+
+func (u *ImageJobUseCase) GetImageJob(ctx context.Context, jobId primitive.ObjectID) (*model.ImageJob, error) {
+	return u.ImageJobService.FindOne(ctx, bson.M{"_id": jobId})
+}
+
+func (u *ImageJobUseCase) RunImageWorkers(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < u.Options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case jobId := <-u.queue:
+					// Let a running job finish on shutdown.
+					u.runImageJob(context.WithoutCancel(ctx), jobId)
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(u.Options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		u.pollImageJobs(ctx)
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (u *ImageJobUseCase) EnsureImageJobIndexes(ctx context.Context) error {
+	if err := u.ImageJobService.SetDeleteFromDatabaseAttribute(ctx, bson.M{"expiresAt": 1}); err != nil {
+		return err
+	}
+
+	return u.ImageJobService.CreateIndex(ctx, bson.D{{Key: "status", Value: 1}, {Key: "runAfter", Value: 1}})
+}
+
+// pollImageJobs queues the pending jobs, after putting back those of crashed attempts.
+func (u *ImageJobUseCase) pollImageJobs(ctx context.Context) {
+	now := time.Now()
+
+	_, err := u.ImageJobService.UpdateMany(
+		ctx,
+		bson.M{"status": model.ImageJobProcessing, "lockedAt": bson.M{"$lt": now.Add(-u.Options.ProcessingTimeout)}},
+		bson.M{"$set": bson.M{"status": model.ImageJobPending, "runAfter": now, "lockedAt": nil}},
+	)
+	if err != nil {
+		logger.FromContext(ctx).Error("Failed to recover image jobs", "error", err)
+	}
+
+	jobs, err := u.ImageJobService.Find(
+		ctx,
+		bson.M{"status": model.ImageJobPending, "runAfter": bson.M{"$lte": now}},
+		options.Find().
+			SetSort(bson.M{"runAfter": 1}).
+			SetLimit(int64(u.Options.QueueSize)).
+			SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		logger.FromContext(ctx).Error("Failed to poll image jobs", "error", err)
+		return
+	}
+
+	for _, job := range jobs {
+		select {
+		case u.queue <- job.ID:
+		default:
+			return
+		}
+	}
+}
+
+func (u *ImageJobUseCase) runImageJob(ctx context.Context, jobId primitive.ObjectID) {
+	log := logger.FromContext(ctx).With("image_job_id", jobId.Hex())
+	now := time.Now()
+
+	// Claim the job, another worker may have it already.
+	claimed, err := u.ImageJobService.UpdateOne(
+		ctx,
+		bson.M{"_id": jobId, "status": model.ImageJobPending},
+		bson.M{
+			"$set": bson.M{"status": model.ImageJobProcessing, "lockedAt": now},
+			"$inc": bson.M{"attempts": 1},
+		},
+	)
+	if err != nil {
+		log.Error("Failed to claim image job", "error", err)
+		return
+	}
+	if claimed == 0 {
+		return
+	}
+
+	job, err := u.ImageJobService.FindOne(ctx, bson.M{"_id": jobId})
+	if err != nil {
+		log.Error("Failed to read image job", "error", err)
+		return
+	}
+
+	result, err := u.processImageJob(ctx, job)
+	if err == nil {
+		expiresAt := time.Now().Add(u.Options.Retention)
+		_, err = u.ImageJobService.UpdateOne(ctx, bson.M{"_id": jobId}, bson.M{"$set": bson.M{
+			"status":    model.ImageJobDone,
+			"result":    result,
+			"error":     "",
+			"lockedAt":  nil,
+			"expiresAt": expiresAt,
+		}})
+		if err != nil {
+			log.Error("Failed to complete image job", "error", err)
+		}
+		return
+	}
+
+	if errors.Is(err, errImageJobDocumentMissing) && time.Since(job.CreatedAt) < u.Options.DocumentTimeout {
+		// Usually enqueued just before its document is inserted, wait without using an attempt.
+		_, err := u.ImageJobService.UpdateOne(ctx, bson.M{"_id": jobId}, bson.M{
+			"$set": bson.M{
+				"status":   model.ImageJobPending,
+				"runAfter": time.Now().Add(u.Options.RetryDelay),
+				"lockedAt": nil,
+			},
+			"$inc": bson.M{"attempts": -1},
+		})
+		if err != nil {
+			log.Error("Failed to update image job", "error", err)
+		}
+		return
+	}
+
+	log.Warn("Image job failed", "error", err, "attempt", job.Attempts)
+
+	update := bson.M{"error": err.Error(), "lockedAt": nil}
+	if job.Attempts >= u.Options.MaxAttempts {
+		update["status"] = model.ImageJobFailed
+		update["expiresAt"] = time.Now().Add(u.Options.Retention)
+	} else {
+		update["status"] = model.ImageJobPending
+		update["runAfter"] = time.Now().Add(u.Options.RetryDelay << (job.Attempts - 1))
+	}
+
+	if _, err := u.ImageJobService.UpdateOne(ctx, bson.M{"_id": jobId}, bson.M{"$set": update}); err != nil {
+		log.Error("Failed to update image job", "error", err)
+	}
+}
+
+// processImageJob saves the variants and sets them in the owning document. Returns the path of each variant.
+func (u *ImageJobUseCase) processImageJob(ctx context.Context, job *model.ImageJob) (map[string]string, error) {
+	backend := u.Options.Backend
+
+	// Skip the resizing while the document is not inserted yet.
+	target := u.TargetService(job.CompanyCode, job.Collection)
+	matching, err := target.CountDocuments(ctx, bson.M{"_id": job.DocumentID})
+	if err != nil {
+		return nil, err
+	}
+	if matching == 0 {
+		return nil, errImageJobDocumentMissing
+	}
+
+	reader, _, err := backend.Get(ctx, job.SourceKey)
+	if err != nil {
+		return nil, fmt.Errorf("error reading original image: %w", err)
+	}
+	defer reader.Close()
+
+	// Already checked on upload, the limits only guard against a corrupted file.
+	img, _, err := storage.DecodeImage(reader, storage.DecodeOptions{
+		MaxBytes:     1 << 30,
+		MaxPixels:    1 << 30,
+		AllowedTypes: []string{"image/jpeg", "image/png"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	variants := make([]storage.Variant, 0, len(job.Variants))
+	for _, variant := range job.Variants {
+		variants = append(variants, storage.Variant{
+			Name:    variant.Name,
+			Width:   variant.Width,
+			Height:  variant.Height,
+			Mode:    storage.ResizeMode(variant.Mode),
+			Format:  storage.Format(variant.Format),
+			Quality: variant.Quality,
+		})
+	}
+
+	keys, err := storage.SaveVariants(ctx, backend, job.Dir, img, variants, storage.SaveImageOptions{SourceType: job.SourceType})
+	if err != nil {
+		return nil, err
+	}
+
+	// Each variant is referenced once per field using it.
+	paths := make(map[string]string, len(keys))
+	set := bson.M{"updatedAt": time.Now()}
+	filter := bson.M{"_id": job.DocumentID}
+	uses := make(map[string]int, len(keys))
+	for name, field := range job.Fields {
+		key, exists := keys[name]
+		if !exists {
+			releaseImageKeys(ctx, backend, keys)
+			return nil, fmt.Errorf("image job has no %s variant for field %s", name, field)
+		}
+
+		set[field] = imageJobPath(key, job.PathPrefix)
+		filter[field] = job.Placeholder
+		uses[name]++
+	}
+	for name, key := range keys {
+		paths[name] = imageJobPath(key, job.PathPrefix)
+
+		// SaveVariants retained each variant once.
+		for i := 1; i < uses[name]; i++ {
+			if err := storage.Retain(ctx, key); err != nil {
+				return nil, err
+			}
+		}
+		if uses[name] == 0 {
+			_, _ = storage.Release(ctx, backend, key)
+		}
+	}
+
+	modified, err := target.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		u.releaseImageJobVariants(ctx, job, keys)
+		return nil, err
+	}
+
+	if modified == 0 {
+		matching, err := target.CountDocuments(ctx, bson.M{"_id": job.DocumentID})
+		if err != nil {
+			u.releaseImageJobVariants(ctx, job, keys)
+			return nil, err
+		}
+		if matching == 0 {
+			// Not inserted yet, or deleted: retried until the attempts run out.
+			u.releaseImageJobVariants(ctx, job, keys)
+			return nil, errImageJobDocumentMissing
+		}
+
+		// The fields were changed meanwhile, the new values win.
+		u.releaseImageJobVariants(ctx, job, keys)
+		return paths, nil
+	}
+
+	// The fields do not hold the placeholder anymore.
+	for range job.Fields {
+		if _, err := storage.Release(ctx, backend, job.SourceKey); err != nil {
+			logger.FromContext(ctx).Warn("Failed to release original image", "error", err, "key", job.SourceKey)
+		}
+	}
+
+	return paths, nil
+}
+
+// releaseImageJobVariants drops the references of variants not stored in the owning document.
+func (u *ImageJobUseCase) releaseImageJobVariants(ctx context.Context, job *model.ImageJob, keys map[string]string) {
+	for name := range job.Fields {
+		if key, exists := keys[name]; exists {
+			_, _ = storage.Release(ctx, u.Options.Backend, key)
+		}
+	}
+}
+
+func releaseImageKeys(ctx context.Context, backend storage.Backend, keys map[string]string) {
+	for _, key := range keys {
+		_, _ = storage.Release(ctx, backend, key)
+	}
+}
+
+// imageJobPath returns the path stored in documents for key: "/" followed by key without prefix.
+func imageJobPath(key string, prefix string) string {
+	if prefix != "" {
+		key = strings.TrimPrefix(key, strings.Trim(prefix, "/")+"/")
+	}
+
+	return "/" + key
+}
+
+type MockImageJobUseCase struct {
+	mock.Mock
+}
+
+func (m *MockImageJobUseCase) EnqueueImageJob(ctx context.Context, data EnqueueImageJobData) (string, *model.ImageJob, error) {
+	args := m.Called(ctx, data)
+	return args.String(0), args.Get(1).(*model.ImageJob), args.Error(2)
+}
+
+func (m *MockImageJobUseCase) GetImageJob(ctx context.Context, jobId primitive.ObjectID) (*model.ImageJob, error) {
+	args := m.Called(ctx, jobId)
+	return args.Get(0).(*model.ImageJob), args.Error(1)
+}
+
+func (m *MockImageJobUseCase) RunImageWorkers(ctx context.Context) {
+	m.Called(ctx)
+}
+
+func (m *MockImageJobUseCase) EnsureImageJobIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
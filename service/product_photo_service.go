@@ -0,0 +1,575 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/susatyo441/go-ta-utils/db"
+	"github.com/susatyo441/go-ta-utils/entity"
+	"github.com/susatyo441/go-ta-utils/logger"
+	"github.com/susatyo441/go-ta-utils/model"
+	"github.com/susatyo441/go-ta-utils/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IProductPhotoUseCase manages the photo gallery of products.
+//
+// Photos are ordered by Key, always 0 to n-1. Product.CoverPhoto is one of the photos, the first one
+// unless chosen with SetProductCoverPhoto. Changes to the gallery of a product are serialized,
+// and the files of removed photos are released from storage.Default() (see storage.Release).
+type IProductPhotoUseCase interface {
+	// Photos of a product, ordered by key.
+	ListProductPhotos(
+		ctx context.Context,
+		productId primitive.ObjectID,
+	) ([]model.ProductPhoto, *entity.HttpError)
+	// Append photos to the gallery, photos being paths relative to storeId as returned by
+	// functions.SaveMultiImages. The first photo becomes the cover of a product without one.
+	// The product must belong to storeId. On error nothing is added and the photos are released.
+	AddProductPhotos(
+		ctx context.Context,
+		storeId primitive.ObjectID,
+		productId primitive.ObjectID,
+		photos []string,
+	) ([]model.ProductPhoto, *entity.HttpError)
+	// Remove a photo and its file. A new cover is promoted when it was the cover.
+	RemoveProductPhoto(
+		ctx context.Context,
+		productId primitive.ObjectID,
+		photoId primitive.ObjectID,
+	) *entity.HttpError
+	// Order the photos as photoIds, which must list every photo of the product.
+	ReorderProductPhotos(
+		ctx context.Context,
+		productId primitive.ObjectID,
+		photoIds []primitive.ObjectID,
+	) ([]model.ProductPhoto, *entity.HttpError)
+	SetProductCoverPhoto(
+		ctx context.Context,
+		productId primitive.ObjectID,
+		photoId primitive.ObjectID,
+	) *entity.HttpError
+	// Delete a product with its photos and their files.
+	DeleteProduct(
+		ctx context.Context,
+		productId primitive.ObjectID,
+	) *entity.HttpError
+	// Create the indexes of the product photos collection.
+	EnsureProductPhotoIndexes(ctx context.Context) error
+}
+
+type ProductPhotoUseCaseOptions struct {
+	// Maximum photos per product. Defaults to 10.
+	MaxPhotos int
+	// How long to wait for a concurrent change of the same gallery. Defaults to 5 seconds.
+	LockTimeout time.Duration
+	// A gallery locked for longer is considered abandoned by a crashed request. Defaults to 30 seconds.
+	LockTTL time.Duration
+	// Defaults to storage.Default().
+	Backend storage.Backend
+}
+
+type ProductPhotoUseCase struct {
+	ProductService      Service[model.Product]
+	ProductPhotoService Service[model.ProductPhoto]
+	Options             ProductPhotoUseCaseOptions
+}
+
+func determineProductPhotoOptions(opts ...ProductPhotoUseCaseOptions) ProductPhotoUseCaseOptions {
+	actualOpts := ProductPhotoUseCaseOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.MaxPhotos <= 0 {
+		actualOpts.MaxPhotos = 10
+	}
+	if actualOpts.LockTimeout == 0 {
+		actualOpts.LockTimeout = 5 * time.Second
+	}
+	if actualOpts.LockTTL == 0 {
+		actualOpts.LockTTL = 30 * time.Second
+	}
+	if actualOpts.Backend == nil {
+		actualOpts.Backend = storage.Default()
+	}
+
+	return actualOpts
+}
+
+func NewCompanyProductPhotoUseCase(companyCode string, opts ...ProductPhotoUseCaseOptions) IProductPhotoUseCase {
+	return &ProductPhotoUseCase{
+		ProductService:      NewCompanyService[model.Product](companyCode, db.ProductModelName),
+		ProductPhotoService: NewCompanyService[model.ProductPhoto](companyCode, db.ProductPhotoModelName),
+		Options:             determineProductPhotoOptions(opts...),
+	}
+}
+
+func NewAdminProductPhotoUseCase(opts ...ProductPhotoUseCaseOptions) IProductPhotoUseCase {
+	return &ProductPhotoUseCase{
+		ProductService:      NewAdminService[model.Product](db.ProductModelName),
+		ProductPhotoService: NewAdminService[model.ProductPhoto](db.ProductPhotoModelName),
+		Options:             determineProductPhotoOptions(opts...),
+	}
+}
+
+func (u *ProductPhotoUseCase) ListProductPhotos(
+	ctx context.Context,
+	productId primitive.ObjectID,
+) ([]model.ProductPhoto, *entity.HttpError) {
+	photos, err := u.findPhotos(ctx, productId)
+	if err != nil {
+		return nil, entity.InternalServerError(err.Error())
+	}
+
+	return photos, nil
+}
+
+func (u *ProductPhotoUseCase) AddProductPhotos(
+	ctx context.Context,
+	storeId primitive.ObjectID,
+	productId primitive.ObjectID,
+	photos []string,
+) ([]model.ProductPhoto, *entity.HttpError) {
+	product, lock, httpErr := u.lockGallery(ctx, productId)
+	if httpErr != nil {
+		u.releasePhotoFiles(ctx, storeId, photos)
+		return nil, httpErr
+	}
+	defer u.unlockGallery(ctx, productId, lock)
+
+	if product.StoreID != storeId {
+		u.releasePhotoFiles(ctx, storeId, photos)
+		return nil, entity.NotFound("Product not found")
+	}
+
+	existing, err := u.findPhotos(ctx, productId)
+	if err != nil {
+		u.releasePhotoFiles(ctx, storeId, photos)
+		return nil, entity.InternalServerError(err.Error())
+	}
+	if len(existing)+len(photos) > u.Options.MaxPhotos {
+		u.releasePhotoFiles(ctx, storeId, photos)
+		return nil, entity.BadRequest(fmt.Sprintf("A product can have at most %d photos", u.Options.MaxPhotos))
+	}
+
+	now := time.Now()
+	added := make([]model.ProductPhoto, 0, len(photos))
+	for i, photo := range photos {
+		added = append(added, model.ProductPhoto{
+			ID:        primitive.NewObjectID(),
+			Key:       len(existing) + i,
+			Photo:     photo,
+			ProductID: productId,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+	if len(added) == 0 {
+		return existing, nil
+	}
+
+	if _, err := u.ProductPhotoService.InsertMany(ctx, added); err != nil {
+		u.releasePhotoFiles(ctx, storeId, photos)
+		return nil, entity.InternalServerError(err.Error())
+	}
+
+	if product.CoverPhoto == "" {
+		if httpErr := u.setCover(ctx, productId, added[0].Photo); httpErr != nil {
+			u.removeAddedPhotos(ctx, storeId, added)
+			return nil, httpErr
+		}
+	}
+
+	return append(existing, added...), nil
+}
+
+func (u *ProductPhotoUseCase) RemoveProductPhoto(
+	ctx context.Context,
+	productId primitive.ObjectID,
+	photoId primitive.ObjectID,
+) *entity.HttpError {
+	product, lock, httpErr := u.lockGallery(ctx, productId)
+	if httpErr != nil {
+		return httpErr
+	}
+	defer u.unlockGallery(ctx, productId, lock)
+
+	photos, err := u.findPhotos(ctx, productId)
+	if err != nil {
+		return entity.InternalServerError(err.Error())
+	}
+
+	var removed *model.ProductPhoto
+	remaining := make([]model.ProductPhoto, 0, len(photos))
+	for i := range photos {
+		if photos[i].ID == photoId {
+			removed = &photos[i]
+			continue
+		}
+		remaining = append(remaining, photos[i])
+	}
+	if removed == nil {
+		return entity.NotFound("Product photo not found")
+	}
+
+	if _, err := u.ProductPhotoService.DeleteOne(ctx, bson.M{"_id": photoId, "productId": productId}); err != nil {
+		return entity.InternalServerError(err.Error())
+	}
+
+	// Without transactions, the photo and the keys are restored when the gallery cannot be updated.
+	if err := u.writeKeys(ctx, remaining); err != nil {
+		u.restoreRemovedPhoto(ctx, *removed, photos)
+		return entity.InternalServerError(err.Error())
+	}
+
+	if product.CoverPhoto == removed.Photo && !containsPhoto(remaining, removed.Photo) {
+		cover := ""
+		if len(remaining) > 0 {
+			cover = remaining[0].Photo
+		}
+		if httpErr := u.setCover(ctx, productId, cover); httpErr != nil {
+			u.restoreRemovedPhoto(ctx, *removed, photos)
+			return httpErr
+		}
+	}
+
+	u.releasePhotoFiles(ctx, product.StoreID, []string{removed.Photo})
+
+	return nil
+}
+
+// removeAddedPhotos deletes the photos inserted by AddProductPhotos and releases their files.
+func (u *ProductPhotoUseCase) removeAddedPhotos(ctx context.Context, storeId primitive.ObjectID, added []model.ProductPhoto) {
+	ids := make([]primitive.ObjectID, 0, len(added))
+	files := make([]string, 0, len(added))
+	for _, photo := range added {
+		ids = append(ids, photo.ID)
+		files = append(files, photo.Photo)
+	}
+
+	_, err := u.ProductPhotoService.DeleteMany(context.WithoutCancel(ctx), bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		// The photos stay in the gallery, so their files must stay too.
+		logger.FromContext(ctx).Error("Failed to remove added product photos", "error", err, "product_id", added[0].ProductID.Hex())
+		return
+	}
+
+	u.releasePhotoFiles(ctx, storeId, files)
+}
+
+// restoreRemovedPhoto inserts back a photo removed by RemoveProductPhoto and the keys of the gallery before.
+func (u *ProductPhotoUseCase) restoreRemovedPhoto(ctx context.Context, removed model.ProductPhoto, photos []model.ProductPhoto) {
+	ctx = context.WithoutCancel(ctx)
+	log := logger.FromContext(ctx).With("product_id", removed.ProductID.Hex(), "photo_id", removed.ID.Hex())
+
+	if _, err := u.ProductPhotoService.InsertOne(ctx, removed); err != nil {
+		log.Error("Failed to restore removed product photo", "error", err)
+	}
+
+	// The keys were contiguous before, an unknown key forces every one to be written.
+	original := make([]model.ProductPhoto, len(photos))
+	copy(original, photos)
+	for i := range original {
+		original[i].Key = -1
+	}
+	if err := u.writeKeys(ctx, original); err != nil {
+		log.Error("Failed to restore product photo keys", "error", err)
+	}
+}
+
+func (u *ProductPhotoUseCase) ReorderProductPhotos(
+	ctx context.Context,
+	productId primitive.ObjectID,
+	photoIds []primitive.ObjectID,
+) ([]model.ProductPhoto, *entity.HttpError) {
+	_, lock, httpErr := u.lockGallery(ctx, productId)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	defer u.unlockGallery(ctx, productId, lock)
+
+	photos, err := u.findPhotos(ctx, productId)
+	if err != nil {
+		return nil, entity.InternalServerError(err.Error())
+	}
+
+	byId := make(map[primitive.ObjectID]model.ProductPhoto, len(photos))
+	for _, photo := range photos {
+		byId[photo.ID] = photo
+	}
+
+	ordered := make([]model.ProductPhoto, 0, len(photoIds))
+	for _, photoId := range photoIds {
+		photo, exists := byId[photoId]
+		if !exists {
+			return nil, entity.BadRequest("Photos must list every photo of the product once")
+		}
+		delete(byId, photoId)
+		ordered = append(ordered, photo)
+	}
+	if len(byId) > 0 {
+		return nil, entity.BadRequest("Photos must list every photo of the product once")
+	}
+
+	if err := u.writeKeys(ctx, ordered); err != nil {
+		return nil, entity.InternalServerError(err.Error())
+	}
+
+	return ordered, nil
+}
+
+func (u *ProductPhotoUseCase) SetProductCoverPhoto(
+	ctx context.Context,
+	productId primitive.ObjectID,
+	photoId primitive.ObjectID,
+) *entity.HttpError {
+	_, lock, httpErr := u.lockGallery(ctx, productId)
+	if httpErr != nil {
+		return httpErr
+	}
+	defer u.unlockGallery(ctx, productId, lock)
+
+	photo, httpErr := u.ProductPhotoService.GetOneOrFail(
+		ctx,
+		bson.M{"_id": photoId, "productId": productId},
+		&GetOneOrFailOptions{Message: "Product photo not found"},
+	)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	return u.setCover(ctx, productId, photo.Photo)
+}
+
+func (u *ProductPhotoUseCase) DeleteProduct(
+	ctx context.Context,
+	productId primitive.ObjectID,
+) *entity.HttpError {
+	product, lock, httpErr := u.lockGallery(ctx, productId)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	photos, err := u.findPhotos(ctx, productId)
+	if err != nil {
+		u.unlockGallery(ctx, productId, lock)
+		return entity.InternalServerError(err.Error())
+	}
+
+	if _, err := u.ProductPhotoService.DeleteMany(ctx, bson.M{"productId": productId}); err != nil {
+		u.unlockGallery(ctx, productId, lock)
+		return entity.InternalServerError(err.Error())
+	}
+	if _, err := u.ProductService.DeleteOne(ctx, bson.M{"_id": productId}); err != nil {
+		u.unlockGallery(ctx, productId, lock)
+		return entity.InternalServerError(err.Error())
+	}
+
+	files := make([]string, 0, len(photos))
+	for _, photo := range photos {
+		files = append(files, photo.Photo)
+	}
+	u.releasePhotoFiles(ctx, product.StoreID, files)
+
+	return nil
+}
+
+func (u *ProductPhotoUseCase) EnsureProductPhotoIndexes(ctx context.Context) error {
+	return u.ProductPhotoService.CreateIndex(ctx, bson.D{{Key: "productId", Value: 1}, {Key: "key", Value: 1}})
+}
+
+// lockGallery waits until it is the only one changing the gallery of the product, and returns the product
+// and the lock, the time written to galleryLockedAt, to give to unlockGallery.
+func (u *ProductPhotoUseCase) lockGallery(
+	ctx context.Context,
+	productId primitive.ObjectID,
+) (*model.Product, time.Time, *entity.HttpError) {
+	deadline := time.Now().Add(u.Options.LockTimeout)
+	delay := 20 * time.Millisecond
+
+	for {
+		// Mongo stores milliseconds, so the lock matches the stored value.
+		now := time.Now().Truncate(time.Millisecond)
+		product, err := u.ProductService.FindOneAndUpdate(
+			ctx,
+			bson.M{
+				"_id": productId,
+				"$or": bson.A{
+					bson.M{"galleryLockedAt": nil},
+					bson.M{"galleryLockedAt": bson.M{"$lt": now.Add(-u.Options.LockTTL)}},
+				},
+			},
+			bson.M{"$set": bson.M{"galleryLockedAt": now}},
+		)
+		if err == nil {
+			return product, now, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			return nil, time.Time{}, entity.InternalServerError(err.Error())
+		}
+
+		// Missing, or locked by another request.
+		count, err := u.ProductService.CountDocuments(ctx, bson.M{"_id": productId})
+		if err != nil {
+			return nil, time.Time{}, entity.InternalServerError(err.Error())
+		}
+		if count == 0 {
+			return nil, time.Time{}, entity.NotFound("Product not found")
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return nil, time.Time{}, entity.Conflict("The photos of this product are being changed, please retry")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, time.Time{}, entity.InternalServerError(ctx.Err().Error())
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, 500*time.Millisecond)
+	}
+}
+
+// unlockGallery releases the lock, unless it expired and another request holds the gallery now.
+func (u *ProductPhotoUseCase) unlockGallery(ctx context.Context, productId primitive.ObjectID, lock time.Time) {
+	_, err := u.ProductService.UpdateOne(
+		context.WithoutCancel(ctx),
+		bson.M{"_id": productId, "galleryLockedAt": lock},
+		bson.M{"$set": bson.M{}, "$unset": bson.M{"galleryLockedAt": ""}},
+	)
+	if err != nil {
+		logger.FromContext(ctx).Error("Failed to unlock product gallery", "error", err, "product_id", productId.Hex())
+	}
+}
+
+func (u *ProductPhotoUseCase) findPhotos(ctx context.Context, productId primitive.ObjectID) ([]model.ProductPhoto, error) {
+	return u.ProductPhotoService.Find(
+		ctx,
+		bson.M{"productId": productId},
+		options.Find().SetSort(bson.D{{Key: "key", Value: 1}, {Key: "createdAt", Value: 1}}),
+	)
+}
+
+// writeKeys sets the key of each photo to its index, writing only the changed ones.
+func (u *ProductPhotoUseCase) writeKeys(ctx context.Context, photos []model.ProductPhoto) error {
+	now := time.Now()
+	models := []mongo.WriteModel{}
+
+	for i := range photos {
+		if photos[i].Key == i {
+			continue
+		}
+
+		photos[i].Key = i
+		photos[i].UpdatedAt = now
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": photos[i].ID}).
+			SetUpdate(bson.M{"$set": bson.M{"key": i, "updatedAt": now}}))
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	_, err := u.ProductPhotoService.BulkWrite(ctx, models)
+	return err
+}
+
+func (u *ProductPhotoUseCase) setCover(ctx context.Context, productId primitive.ObjectID, photo string) *entity.HttpError {
+	_, err := u.ProductService.UpdateOne(ctx, bson.M{"_id": productId}, bson.M{"$set": bson.M{"coverPhoto": photo}})
+	if err != nil {
+		return entity.InternalServerError(err.Error())
+	}
+
+	return nil
+}
+
+// releasePhotoFiles releases the files of photos, paths relative to the store.
+// Released even when ctx is canceled, so an aborted request does not leak the references.
+func (u *ProductPhotoUseCase) releasePhotoFiles(ctx context.Context, storeId primitive.ObjectID, photos []string) {
+	ctx = context.WithoutCancel(ctx)
+
+	for _, photo := range photos {
+		key := path.Join(storeId.Hex(), photo)
+		if _, err := storage.Release(ctx, u.Options.Backend, key); err != nil {
+			logger.FromContext(ctx).Warn("Failed to release product photo", "error", err, "key", key)
+		}
+	}
+}
+
+func containsPhoto(photos []model.ProductPhoto, photo string) bool {
+	for _, p := range photos {
+		if p.Photo == photo {
+			return true
+		}
+	}
+
+	return false
+}
+
+type MockProductPhotoUseCase struct {
+	mock.Mock
+}
+
+func (m *MockProductPhotoUseCase) ListProductPhotos(
+	ctx context.Context,
+	productId primitive.ObjectID,
+) ([]model.ProductPhoto, *entity.HttpError) {
+	args := m.Called(ctx, productId)
+	return args.Get(0).([]model.ProductPhoto), args.Get(1).(*entity.HttpError)
+}
+
+func (m *MockProductPhotoUseCase) AddProductPhotos(
+	ctx context.Context,
+	storeId primitive.ObjectID,
+	productId primitive.ObjectID,
+	photos []string,
+) ([]model.ProductPhoto, *entity.HttpError) {
+	args := m.Called(ctx, storeId, productId, photos)
+	return args.Get(0).([]model.ProductPhoto), args.Get(1).(*entity.HttpError)
+}
+
+func (m *MockProductPhotoUseCase) RemoveProductPhoto(
+	ctx context.Context,
+	productId primitive.ObjectID,
+	photoId primitive.ObjectID,
+) *entity.HttpError {
+	args := m.Called(ctx, productId, photoId)
+	return args.Get(0).(*entity.HttpError)
+}
+
+func (m *MockProductPhotoUseCase) ReorderProductPhotos(
+	ctx context.Context,
+	productId primitive.ObjectID,
+	photoIds []primitive.ObjectID,
+) ([]model.ProductPhoto, *entity.HttpError) {
+	args := m.Called(ctx, productId, photoIds)
+	return args.Get(0).([]model.ProductPhoto), args.Get(1).(*entity.HttpError)
+}
+
+func (m *MockProductPhotoUseCase) SetProductCoverPhoto(
+	ctx context.Context,
+	productId primitive.ObjectID,
+	photoId primitive.ObjectID,
+) *entity.HttpError {
+	args := m.Called(ctx, productId, photoId)
+	return args.Get(0).(*entity.HttpError)
+}
+
+func (m *MockProductPhotoUseCase) DeleteProduct(
+	ctx context.Context,
+	productId primitive.ObjectID,
+) *entity.HttpError {
+	args := m.Called(ctx, productId)
+	return args.Get(0).(*entity.HttpError)
+}
+
+func (m *MockProductPhotoUseCase) EnsureProductPhotoIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
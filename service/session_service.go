@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/susatyo441/go-ta-utils/db"
+	"github.com/susatyo441/go-ta-utils/entity"
+	"github.com/susatyo441/go-ta-utils/functions"
+	"github.com/susatyo441/go-ta-utils/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Number of rotated refresh token hashes kept per session for reuse detection.
+const maxPreviousTokenHashes = 20
+
+const (
+	SessionRevokedLogout      = "logout"
+	SessionRevokedLogoutAll   = "logout all devices"
+	SessionRevokedTokenReuse  = "refresh token reuse"
+	SessionRevokedUserBlocked = "user disabled"
+)
+
+type ISessionUseCase interface {
+	// Create a session and return it with its first refresh token.
+	CreateSession(
+		ctx context.Context,
+		data CreateSessionData,
+	) (*model.Session, string, error)
+	// Rotate a refresh token and return the session with the new refresh token.
+	// Reusing an already rotated refresh token revokes the session.
+	RefreshSession(
+		ctx context.Context,
+		refreshToken string,
+	) (*model.Session, string, *entity.HttpError)
+	// Revoke one session, e.g. on logout.
+	RevokeSession(
+		ctx context.Context,
+		sessionId primitive.ObjectID,
+		reason string,
+	) error
+	// Revoke every active session of a user ("log out all devices"), returns the number revoked.
+	RevokeUserSessions(
+		ctx context.Context,
+		userId primitive.ObjectID,
+		reason string,
+	) (int, error)
+	// Whether the session exists, is not revoked and is not expired.
+	// Satisfies middleware.SessionChecker.
+	IsSessionActive(
+		ctx context.Context,
+		sessionId primitive.ObjectID,
+	) (bool, error)
+	// Create the indexes of the sessions collection, expired sessions are deleted by Mongo.
+	EnsureSessionIndexes(ctx context.Context) error
+}
+
+type SessionUseCaseOptions struct {
+	// Lifetime of a session, i.e. of its refresh tokens. Defaults to 30 days.
+	RefreshTTL time.Duration
+}
+
+type CreateSessionData struct {
+	UserID    primitive.ObjectID
+	StoreID   primitive.ObjectID
+	UserAgent *string
+	IP        *string
+}
+
+type SessionUseCase struct {
+	SessionService Service[model.Session]
+	Options        SessionUseCaseOptions
+}
+
+func determineSessionOptions(opts ...SessionUseCaseOptions) SessionUseCaseOptions {
+	actualOpts := SessionUseCaseOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.RefreshTTL == 0 {
+		actualOpts.RefreshTTL = 30 * 24 * time.Hour
+	}
+
+	return actualOpts
+}
+
+func NewCompanySessionUseCase(companyCode string, opts ...SessionUseCaseOptions) ISessionUseCase {
+	return &SessionUseCase{
+		SessionService: NewCompanyService[model.Session](companyCode, db.SessionModelName),
+		Options:        determineSessionOptions(opts...),
+	}
+}
+
+func NewAdminSessionUseCase(opts ...SessionUseCaseOptions) ISessionUseCase {
+	return &SessionUseCase{
+		SessionService: NewAdminService[model.Session](db.SessionModelName),
+		Options:        determineSessionOptions(opts...),
+	}
+}
+
+func (u *SessionUseCase) CreateSession(
+	ctx context.Context,
+	data CreateSessionData,
+) (*model.Session, string, error) {
+	secret, err := newRefreshSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := model.Session{
+		ID:                  primitive.NewObjectID(),
+		UserID:              data.UserID,
+		StoreID:             data.StoreID,
+		UserAgent:           data.UserAgent,
+		IP:                  data.IP,
+		RefreshTokenHash:    hashSecret(secret),
+		PreviousTokenHashes: []string{},
+		ExpiresAt:           now.Add(u.Options.RefreshTTL),
+		LastUsedAt:          now,
+	}
+
+	if _, err := u.SessionService.InsertOne(ctx, session); err != nil {
+		return nil, "", err
+	}
+
+	return &session, formatRefreshToken(session.ID, secret), nil
+}
+
+func (u *SessionUseCase) RefreshSession(
+	ctx context.Context,
+	refreshToken string,
+) (*model.Session, string, *entity.HttpError) {
+	sessionId, secret, ok := parseRefreshToken(refreshToken)
+	if !ok {
+		return nil, "", entity.Unauthorized("Invalid refresh token")
+	}
+
+	newSecret, err := newRefreshSecret()
+	if err != nil {
+		return nil, "", entity.InternalServerError(err.Error())
+	}
+
+	now := time.Now()
+	oldHash := hashSecret(secret)
+
+	// Rotate atomically, so a token can only be exchanged once even on concurrent requests.
+	session, updateErr := u.SessionService.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"_id":              sessionId,
+			"refreshTokenHash": oldHash,
+			"revokedAt":        nil,
+			"expiresAt":        bson.M{"$gt": now},
+		},
+		bson.M{
+			"$set": bson.M{
+				"refreshTokenHash": hashSecret(newSecret),
+				"lastUsedAt":       now,
+			},
+			"$push": bson.M{
+				"previousTokenHashes": bson.M{"$each": bson.A{oldHash}, "$slice": -maxPreviousTokenHashes},
+			},
+		},
+	)
+	if updateErr == nil {
+		return session, formatRefreshToken(session.ID, newSecret), nil
+	}
+	if updateErr != mongo.ErrNoDocuments {
+		return nil, "", entity.InternalServerError(updateErr.Error())
+	}
+
+	// Not rotated: find out whether an old token is being replayed.
+	existing, findErr := u.SessionService.FindOne(ctx, bson.M{"_id": sessionId})
+	if findErr == mongo.ErrNoDocuments {
+		return nil, "", entity.Unauthorized("Invalid refresh token")
+	}
+	if findErr != nil {
+		return nil, "", entity.InternalServerError(findErr.Error())
+	}
+
+	if existing.RevokedAt == nil && functions.Any(existing.PreviousTokenHashes, func(hash string, _ int) bool {
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(oldHash)) == 1
+	}) {
+		// The token was stolen or leaked: end the session for both holders.
+		if err := u.RevokeSession(ctx, existing.ID, SessionRevokedTokenReuse); err != nil {
+			return nil, "", entity.InternalServerError(err.Error())
+		}
+		return nil, "", entity.Unauthorized("Refresh token reused, session revoked")
+	}
+
+	if existing.RevokedAt != nil {
+		return nil, "", entity.Unauthorized("Session revoked")
+	}
+	if !existing.ExpiresAt.After(now) {
+		return nil, "", entity.Unauthorized("Session expired")
+	}
+
+	return nil, "", entity.Unauthorized("Invalid refresh token")
+}
+
+func (u *SessionUseCase) RevokeSession(
+	ctx context.Context,
+	sessionId primitive.ObjectID,
+	reason string,
+) error {
+	_, err := u.SessionService.UpdateOne(
+		ctx,
+		bson.M{"_id": sessionId, "revokedAt": nil},
+		bson.M{"$set": bson.M{"revokedAt": time.Now(), "revokedReason": reason}},
+	)
+
+	return err
+}
+
+func (u *SessionUseCase) RevokeUserSessions(
+	ctx context.Context,
+	userId primitive.ObjectID,
+	reason string,
+) (int, error) {
+	return u.SessionService.UpdateMany(
+		ctx,
+		bson.M{"userId": userId, "revokedAt": nil},
+		bson.M{"$set": bson.M{"revokedAt": time.Now(), "revokedReason": reason}},
+	)
+}
+
+func (u *SessionUseCase) IsSessionActive(
+	ctx context.Context,
+	sessionId primitive.ObjectID,
+) (bool, error) {
+	count, err := u.SessionService.CountDocuments(ctx, bson.M{
+		"_id":       sessionId,
+		"revokedAt": nil,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (u *SessionUseCase) EnsureSessionIndexes(ctx context.Context) error {
+	if err := u.SessionService.SetDeleteFromDatabaseAttribute(ctx, bson.M{"expiresAt": 1}); err != nil {
+		return err
+	}
+
+	return u.SessionService.CreateIndex(ctx, bson.D{{Key: "userId", Value: 1}, {Key: "revokedAt", Value: 1}})
+}
+
+// Refresh tokens are "<session id>.<random secret>", only the secret hash is stored.
+func formatRefreshToken(sessionId primitive.ObjectID, secret string) string {
+	return sessionId.Hex() + "." + secret
+}
+
+func parseRefreshToken(refreshToken string) (primitive.ObjectID, string, bool) {
+	hexId, secret, found := strings.Cut(refreshToken, ".")
+	if !found || secret == "" {
+		return primitive.NilObjectID, "", false
+	}
+
+	sessionId, err := primitive.ObjectIDFromHex(hexId)
+	if err != nil {
+		return primitive.NilObjectID, "", false
+	}
+
+	return sessionId, secret, true
+}
+
+func newRefreshSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", errors.New("failed to generate refresh token")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// Secrets are random, a fast hash is enough.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+type MockSessionUseCase struct {
+	mock.Mock
+}
+
+func (m *MockSessionUseCase) CreateSession(
+	ctx context.Context,
+	data CreateSessionData,
+) (*model.Session, string, error) {
+	args := m.Called(ctx, data)
+	return args.Get(0).(*model.Session), args.String(1), args.Error(2)
+}
+
+func (m *MockSessionUseCase) RefreshSession(
+	ctx context.Context,
+	refreshToken string,
+) (*model.Session, string, *entity.HttpError) {
+	args := m.Called(ctx, refreshToken)
+	return args.Get(0).(*model.Session), args.String(1), args.Get(2).(*entity.HttpError)
+}
+
+func (m *MockSessionUseCase) RevokeSession(
+	ctx context.Context,
+	sessionId primitive.ObjectID,
+	reason string,
+) error {
+	args := m.Called(ctx, sessionId, reason)
+	return args.Error(0)
+}
+
+func (m *MockSessionUseCase) RevokeUserSessions(
+	ctx context.Context,
+	userId primitive.ObjectID,
+	reason string,
+) (int, error) {
+	args := m.Called(ctx, userId, reason)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockSessionUseCase) IsSessionActive(
+	ctx context.Context,
+	sessionId primitive.ObjectID,
+) (bool, error) {
+	args := m.Called(ctx, sessionId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSessionUseCase) EnsureSessionIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
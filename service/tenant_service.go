@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/susatyo441/go-ta-utils/db"
+	"github.com/susatyo441/go-ta-utils/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Create a service on the database of a resolved tenant, like NewCompanyService
+// or NewPartnerService depending on the tenant kind.
+//
+// Unlike them it does not switch the database of the db package, so it is safe to call per request
+// (and from background goroutines) while other requests use other tenants.
+//
+// EXAMPLE:
+//
+//	tenant, _ := middleware.CurrentTenant(ctx)
+//	productService := service.NewTenantService[model.Product](tenant, db.ProductModelName)
+func NewTenantService[T any](
+	tenant *model.Tenant,
+	collection string,
+	opts ...BaseServiceOptions,
+) *BaseService[T] {
+	dbName := db.CompanyDbName(tenant.Code)
+	if tenant.Kind == model.TenantKindPartner {
+		dbName = db.PartnerDbName(tenant.Code)
+	}
+
+	return newDatabaseService[T](dbName, collection, opts...)
+}
+
+// newDatabaseService creates a service on a collection of dbName, without switching the database of the db package.
+func newDatabaseService[T any](dbName string, collection string, opts ...BaseServiceOptions) *BaseService[T] {
+	return &BaseService[T]{
+		collection: db.ConnectMongo().Database(dbName).Collection(collection),
+		options:    determineOptions(defaultBaseServiceOptions, opts...),
+	}
+}
+
+type ITenantUseCase interface {
+	// Find a tenant by code or subdomain, nil when unknown.
+	// Satisfies middleware.TenantRegistry.
+	FindTenant(ctx context.Context, codeOrSubdomain string) (*model.Tenant, error)
+	// Create the indexes of the tenants collection.
+	EnsureTenantIndexes(ctx context.Context) error
+}
+
+type TenantUseCase struct {
+	TenantService Service[model.Tenant]
+}
+
+func NewAdminTenantUseCase() ITenantUseCase {
+	return &TenantUseCase{
+		TenantService: NewAdminService[model.Tenant](db.TenantModelName),
+	}
+}
+
+func (u *TenantUseCase) FindTenant(ctx context.Context, codeOrSubdomain string) (*model.Tenant, error) {
+	tenant, err := u.TenantService.FindOne(ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"code": codeOrSubdomain},
+			bson.M{"subdomains": codeOrSubdomain},
+		},
+	})
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tenant, nil
+}
+
+func (u *TenantUseCase) EnsureTenantIndexes(ctx context.Context) error {
+	if err := u.TenantService.MakeUnique(ctx, bson.M{"code": 1}); err != nil {
+		return err
+	}
+
+	return u.TenantService.CreateIndex(ctx, bson.M{"subdomains": 1})
+}
+
+type MockTenantUseCase struct {
+	mock.Mock
+}
+
+func (m *MockTenantUseCase) FindTenant(ctx context.Context, codeOrSubdomain string) (*model.Tenant, error) {
+	args := m.Called(ctx, codeOrSubdomain)
+	return args.Get(0).(*model.Tenant), args.Error(1)
+}
+
+func (m *MockTenantUseCase) EnsureTenantIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
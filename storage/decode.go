@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // registers the WebP decoder
+)
+
+var (
+	ErrImageTooLarge        = errors.New("image file is too large")
+	ErrImageTooManyPixels   = errors.New("image dimensions are too large")
+	ErrUnsupportedImageType = errors.New("unsupported image type")
+)
+
+type DecodeOptions struct {
+	// Maximum size of the file in bytes. Defaults to the IMAGE_MAX_BYTES env var, or 10 MiB.
+	MaxBytes int64
+	// Maximum width x height, checked before decoding against decompression bombs.
+	// Defaults to the IMAGE_MAX_PIXELS env var, or 40 megapixels.
+	MaxPixels int
+	// MIME types accepted, sniffed from the content (the file name and Content-Type header are ignored).
+	// Defaults to the comma separated IMAGE_ALLOWED_TYPES env var, or DefaultImageTypes.
+	AllowedTypes []string
+}
+
+// Image types accepted by default.
+var DefaultImageTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
+func determineDecodeOptions(opts ...DecodeOptions) DecodeOptions {
+	actualOpts := DecodeOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	if actualOpts.MaxBytes == 0 {
+		actualOpts.MaxBytes = 10 << 20
+		if value, err := strconv.ParseInt(os.Getenv("IMAGE_MAX_BYTES"), 10, 64); err == nil && value > 0 {
+			actualOpts.MaxBytes = value
+		}
+	}
+	if actualOpts.MaxPixels == 0 {
+		actualOpts.MaxPixels = 40_000_000
+		if value, err := strconv.Atoi(os.Getenv("IMAGE_MAX_PIXELS")); err == nil && value > 0 {
+			actualOpts.MaxPixels = value
+		}
+	}
+	if len(actualOpts.AllowedTypes) == 0 {
+		actualOpts.AllowedTypes = DefaultImageTypes
+		if value := os.Getenv("IMAGE_ALLOWED_TYPES"); value != "" {
+			actualOpts.AllowedTypes = strings.Split(value, ",")
+			for i, mimeType := range actualOpts.AllowedTypes {
+				actualOpts.AllowedTypes[i] = strings.TrimSpace(mimeType)
+			}
+		}
+	}
+
+	return actualOpts
+}
+
+// DecodeImage decodes an uploaded image within the limits of opts.
+// Returns the image and its sniffed MIME type.
+//
+// The image is rotated upright according to its EXIF orientation. The decoded image carries
+// no metadata, so images saved from it are stripped of EXIF (GPS location, camera...).
+//
+// Errors wrap ErrImageTooLarge, ErrImageTooManyPixels or ErrUnsupportedImageType
+// when the upload is rejected, see DecodeErrorStatus.
+func DecodeImage(r io.Reader, opts ...DecodeOptions) (image.Image, string, error) {
+	actualOpts := determineDecodeOptions(opts...)
+
+	data, err := io.ReadAll(io.LimitReader(r, actualOpts.MaxBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading image: %w", err)
+	}
+	if int64(len(data)) > actualOpts.MaxBytes {
+		return nil, "", fmt.Errorf("%w, maximum is %d bytes", ErrImageTooLarge, actualOpts.MaxBytes)
+	}
+
+	mimeType := http.DetectContentType(data)
+	if !slices.Contains(actualOpts.AllowedTypes, mimeType) {
+		return nil, "", fmt.Errorf("%w: %s", ErrUnsupportedImageType, mimeType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("error decoding image: %w", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, "", fmt.Errorf("error decoding image: empty image")
+	}
+	if config.Width > actualOpts.MaxPixels/config.Height {
+		return nil, "", fmt.Errorf(
+			"%w: %dx%d, maximum is %d pixels",
+			ErrImageTooManyPixels,
+			config.Width,
+			config.Height,
+			actualOpts.MaxPixels,
+		)
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, "", fmt.Errorf("error decoding image: %w", err)
+	}
+
+	return img, mimeType, nil
+}
+
+// DecodeUpload is DecodeImage for a multipart file, rejecting it by its declared size before reading it.
+func DecodeUpload(fileHeader *multipart.FileHeader, opts ...DecodeOptions) (image.Image, string, error) {
+	actualOpts := determineDecodeOptions(opts...)
+
+	if fileHeader.Size > actualOpts.MaxBytes {
+		return nil, "", fmt.Errorf("%w, maximum is %d bytes", ErrImageTooLarge, actualOpts.MaxBytes)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return DecodeImage(file, actualOpts)
+}
+
+// DecodeErrorStatus returns the HTTP status of a DecodeImage error:
+// 413 for too large images, 415 for unsupported types, 400 otherwise.
+func DecodeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrImageTooLarge), errors.Is(err, ErrImageTooManyPixels):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUnsupportedImageType):
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusBadRequest
+	}
+}
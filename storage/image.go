@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+var errEmptyImage = errors.New("storage: empty image")
+
+// Naming chooses the file names of SaveImage.
+type Naming string
+
+const (
+	// SHA-256 of the encoded image: identical images share one file. The default when reference counting
+	// is enabled (see SetReferenceCounter), NamingULID is used without it since a shared file would be
+	// deleted by the first Release. Keep such dirs per tenant or store, so tenants never share files.
+	NamingContentHash Naming = "content hash"
+	// A new ULID per image: never collides, never deduplicated.
+	NamingULID Naming = "ulid"
+)
+
+// Format chooses the encoding of saved images.
+// There is no pure Go WebP encoder, so WebP uploads are saved as PNG or JPEG.
+type Format string
+
+const (
+	// PNG when the image has transparent pixels, JPEG otherwise. The default.
+	FormatAuto Format = ""
+	// The format of the upload (SaveImageOptions.SourceType) when it can be encoded, FormatAuto otherwise.
+	FormatOriginal Format = "original"
+	FormatJPEG     Format = "jpeg"
+	FormatPNG      Format = "png"
+)
+
+type SaveImageOptions struct {
+	// Defaults to NamingContentHash, NamingULID without reference counting.
+	Naming Naming
+	// Defaults to FormatAuto.
+	Format Format
+	// MIME type of the upload, as returned by DecodeImage. Used by FormatOriginal.
+	SourceType string
+	// JPEG quality, 1 to 100. Defaults to DefaultJPEGQuality.
+	Quality int
+	// PNG compression. Defaults to png.DefaultCompression.
+	Compression png.CompressionLevel
+}
+
+// PutImage encodes img in the format of the extension of key (".png", JPEG otherwise) and stores it at key.
+func PutImage(ctx context.Context, backend Backend, key string, img image.Image) error {
+	format := FormatJPEG
+	if strings.EqualFold(path.Ext(key), ".png") {
+		format = FormatPNG
+	}
+
+	data, contentType, _, err := encodeImage(img, SaveImageOptions{Format: format})
+	if err != nil {
+		return err
+	}
+
+	return backend.Put(ctx, key, bytes.NewReader(data), PutOptions{ContentType: contentType})
+}
+
+// SaveImage encodes img, stores it in dir and retains a reference to it (see SetReferenceCounter).
+// Returns the key of the file, "<dir>/<name>.<jpg|png>", the extension matching the format.
+//
+// With NamingContentHash an identical image already stored is not written again, so uploading
+// the same product photo twice uses one file, deleted once both references are released.
+// The file is written whenever the reference is the first one, so a concurrent Release
+// deleting the previous copy cannot leave the new reference without a file.
+//
+// EXAMPLE:
+//
+//	img, mimeType, err := storage.DecodeUpload(fileHeader)
+//	// ...
+//	key, err := storage.SaveImage(ctx, storage.Default(), storeId.Hex()+"/logo", img, storage.SaveImageOptions{
+//		Format:     storage.FormatOriginal,
+//		SourceType: mimeType,
+//	})
+//	// ...
+//	storage.Release(ctx, storage.Default(), key)
+func SaveImage(ctx context.Context, backend Backend, dir string, img image.Image, opts ...SaveImageOptions) (string, error) {
+	actualOpts := SaveImageOptions{}
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	data, contentType, extension, err := encodeImage(img, actualOpts)
+	if err != nil {
+		return "", err
+	}
+
+	counter := References()
+
+	name := NewULID(time.Now())
+	if actualOpts.Naming != NamingULID && counter != nil {
+		sum := sha256.Sum256(data)
+		name = hex.EncodeToString(sum[:])
+	}
+
+	key, err := CleanKey(path.Join(dir, name+extension))
+	if err != nil {
+		return "", err
+	}
+
+	// Retain before writing: a file with other references is not deleted meanwhile.
+	count := 1
+	if counter != nil {
+		count, err = counter.Retain(ctx, key)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	write := count <= 1
+	if !write {
+		_, statErr := backend.Stat(ctx, key)
+		if statErr != nil && !errors.Is(statErr, ErrNotFound) {
+			releaseReference(ctx, counter, key)
+			return "", statErr
+		}
+		// Counted but missing, e.g. deleted by hand.
+		write = statErr != nil
+	}
+
+	if write {
+		if err := backend.Put(ctx, key, bytes.NewReader(data), PutOptions{ContentType: contentType}); err != nil {
+			releaseReference(ctx, counter, key)
+			return "", err
+		}
+	}
+
+	return key, nil
+}
+
+// releaseReference undoes the Retain of a failed save, leaving any file to its other references.
+func releaseReference(ctx context.Context, counter ReferenceCounter, key string) {
+	if counter != nil {
+		_, _ = counter.Release(ctx, key)
+	}
+}
+
+// encodeImage returns the encoded image, its content type and file extension.
+func encodeImage(img image.Image, opts SaveImageOptions) ([]byte, string, string, error) {
+	if img == nil || img.Bounds().Empty() {
+		return nil, "", "", errEmptyImage
+	}
+
+	format := opts.Format
+	if format == FormatOriginal {
+		switch opts.SourceType {
+		case "image/jpeg":
+			format = FormatJPEG
+		case "image/png":
+			format = FormatPNG
+		default:
+			format = FormatAuto
+		}
+	}
+	if format != FormatJPEG && format != FormatPNG {
+		format = FormatJPEG
+		if !isOpaque(img) {
+			format = FormatPNG
+		}
+	}
+
+	var buf bytes.Buffer
+	if format == FormatPNG {
+		encoder := png.Encoder{CompressionLevel: opts.Compression}
+		if err := encoder.Encode(&buf, img); err != nil {
+			return nil, "", "", fmt.Errorf("error encoding image: %w", err)
+		}
+		return buf.Bytes(), "image/png", ".png", nil
+	}
+
+	quality := opts.Quality
+	if quality <= 0 || quality > 100 {
+		quality = DefaultJPEGQuality
+	}
+	if err := imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(quality)); err != nil {
+		return nil, "", "", fmt.Errorf("error encoding image: %w", err)
+	}
+
+	return buf.Bytes(), "image/jpeg", ".jpg", nil
+}
+
+// isOpaque reports whether every pixel of img is fully opaque.
+func isOpaque(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return opaque.Opaque()
+	}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalBackend stores files in a directory.
+type LocalBackend struct {
+	root      string
+	publicURL string
+}
+
+// NewLocalBackend is a constructor to initialize LocalBackend. root is created when missing,
+// publicURL is the base URL the root is served under (empty for "/<key>").
+func NewLocalBackend(root string, publicURL string) (*LocalBackend, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("storage: invalid root: %w", err)
+	}
+
+	if err := os.MkdirAll(absRoot, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("storage: creating root: %w", err)
+	}
+
+	return &LocalBackend{root: absRoot, publicURL: publicURL}, nil
+}
+
+// Root returns the absolute root directory.
+func (b *LocalBackend) Root() string {
+	return b.root
+}
+
+func (b *LocalBackend) Put(_ context.Context, key string, r io.Reader, _ PutOptions) error {
+	filePath, err := b.resolve(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return fmt.Errorf("storage: creating directory: %w", err)
+	}
+
+	// Write to a temporary file then rename, so readers never see a partial file.
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("storage: creating file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("storage: writing file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("storage: writing file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("storage: writing file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return fmt.Errorf("storage: writing file: %w", err)
+	}
+
+	return nil
+}
+
+func (b *LocalBackend) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	info, err := b.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filePath, err := b.resolve(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, notFoundOr(err)
+	}
+
+	return file, info, nil
+}
+
+func (b *LocalBackend) Delete(ctx context.Context, key string) error {
+	// Also rejects directories, which os.Remove would delete when empty.
+	if _, err := b.Stat(ctx, key); err != nil {
+		return err
+	}
+
+	filePath, err := b.resolve(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil {
+		return notFoundOr(err)
+	}
+
+	return nil
+}
+
+func (b *LocalBackend) Stat(_ context.Context, key string) (*ObjectInfo, error) {
+	filePath, err := b.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	if stat.IsDir() {
+		return nil, ErrNotFound
+	}
+
+	cleaned, _ := CleanKey(key)
+	return &ObjectInfo{
+		Key:         cleaned,
+		Size:        stat.Size(),
+		ContentType: contentTypeByExtension(cleaned),
+		ModTime:     stat.ModTime(),
+		ETag:        fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size()),
+	}, nil
+}
+
+func (b *LocalBackend) URL(key string) string {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return ""
+	}
+
+	return joinURL(b.publicURL, cleaned)
+}
+
+// path returns the file path of key, always inside the root.
+func (b *LocalBackend) path(key string) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(b.root, filepath.FromSlash(cleaned)), nil
+}
+
+// resolve returns the file path of key with the symbolic links of its directory resolved,
+// and rejects it with ErrInvalidKey when a link, of a directory or of the file itself, leads outside the root.
+// Every access goes through it, so a link under the root never exposes other files.
+// Directories not created yet are kept as is, below their deepest existing parent.
+func (b *LocalBackend) resolve(key string) (string, error) {
+	filePath, err := b.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	root, err := filepath.EvalSymlinks(b.root)
+	if err != nil {
+		return "", notFoundOr(err)
+	}
+
+	dir, missing := filepath.Dir(filePath), ""
+	for {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			dir = filepath.Join(resolved, missing)
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) || dir == b.root {
+			return "", notFoundOr(err)
+		}
+
+		missing = filepath.Join(filepath.Base(dir), missing)
+		dir = filepath.Dir(dir)
+	}
+
+	if !isWithin(root, dir) {
+		return "", ErrInvalidKey
+	}
+
+	resolved := filepath.Join(dir, filepath.Base(filePath))
+	if target, err := filepath.EvalSymlinks(resolved); err == nil && !isWithin(root, target) {
+		return "", ErrInvalidKey
+	}
+
+	return resolved, nil
+}
+
+func (b *LocalBackend) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	// Walk the deepest directory containing every key with the prefix.
+	dir := b.root
+	if index := strings.LastIndex(prefix, "/"); index >= 0 {
+		prefixDir, err := CleanKey(prefix[:index])
+		if err != nil {
+			return err
+		}
+		dir = filepath.Join(b.root, filepath.FromSlash(prefixDir))
+	}
+
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Symbolic links are not followed, temporary uploads are skipped.
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+
+		relative, err := filepath.Rel(b.root, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := b.Stat(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			// Deleted meanwhile.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		return fn(*info)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		// Nothing stored under the prefix yet.
+		return nil
+	}
+
+	return err
+}
+
+// isWithin reports whether filePath is root or inside it.
+func isWithin(root string, filePath string) bool {
+	relative, err := filepath.Rel(root, filePath)
+	if err != nil {
+		return false
+	}
+
+	return relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+func notFoundOr(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return fmt.Errorf("storage: %w", err)
+}
+
+func contentTypeByExtension(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
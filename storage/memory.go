@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryObject struct {
+	data []byte
+	info ObjectInfo
+}
+
+// MemoryBackend keeps files in memory, a stand-in for tests and local development.
+type MemoryBackend struct {
+	publicURL string
+
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+// NewMemoryBackend is a constructor to initialize MemoryBackend
+func NewMemoryBackend(publicURL string) *MemoryBackend {
+	return &MemoryBackend{
+		publicURL: publicURL,
+		objects:   map[string]memoryObject{},
+	}
+}
+
+func (b *MemoryBackend) Put(_ context.Context, key string, r io.Reader, opts PutOptions) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = contentTypeByExtension(cleaned)
+	}
+	sum := md5.Sum(data)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.objects[cleaned] = memoryObject{
+		data: data,
+		info: ObjectInfo{
+			Key:         cleaned,
+			Size:        int64(len(data)),
+			ContentType: contentType,
+			ModTime:     time.Now(),
+			ETag:        `"` + hex.EncodeToString(sum[:]) + `"`,
+		},
+	}
+
+	return nil
+}
+
+func (b *MemoryBackend) Get(_ context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	object, err := b.object(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info := object.info
+	return io.NopCloser(bytes.NewReader(object.data)), &info, nil
+}
+
+func (b *MemoryBackend) Delete(_ context.Context, key string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exist := b.objects[cleaned]; !exist {
+		return ErrNotFound
+	}
+	delete(b.objects, cleaned)
+
+	return nil
+}
+
+func (b *MemoryBackend) Stat(_ context.Context, key string) (*ObjectInfo, error) {
+	object, err := b.object(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info := object.info
+	return &info, nil
+}
+
+func (b *MemoryBackend) URL(key string) string {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return ""
+	}
+
+	return joinURL(b.publicURL, cleaned)
+}
+
+func (b *MemoryBackend) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	for _, key := range b.Keys(prefix) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		info, err := b.Stat(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			// Deleted meanwhile.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(*info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Keys returns the stored keys starting with prefix, sorted.
+func (b *MemoryBackend) Keys(prefix string) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	keys := []string{}
+	for key := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (b *MemoryBackend) object(key string) (memoryObject, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return memoryObject{}, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	object, exist := b.objects[cleaned]
+	if !exist {
+		return memoryObject{}, ErrNotFound
+	}
+
+	return object, nil
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"sync"
+)
+
+// ReferenceCounter counts the documents (product photos, store logos, profile pictures...)
+// referencing each key, so deduplicated files are only deleted once unused.
+// Implemented by service.IFileReferenceUseCase and MemoryReferenceCounter.
+type ReferenceCounter interface {
+	// Retain adds a reference to key and returns the new count.
+	Retain(ctx context.Context, key string) (int, error)
+	// Release removes a reference to key and returns the remaining count.
+	// Keys never retained (e.g. saved before counting was enabled) return 0.
+	Release(ctx context.Context, key string) (int, error)
+	// Forget drops the count of key when no reference is left, reporting whether the file can be deleted.
+	// Keys never retained can be deleted, keys still referenced are kept.
+	Forget(ctx context.Context, key string) (bool, error)
+}
+
+var (
+	referenceCounter   ReferenceCounter
+	referenceCounterMu sync.RWMutex
+)
+
+// SetReferenceCounter enables reference counting in SaveImage and Release, and with it content-hash naming.
+// Without it, SaveImage names every file uniquely and every Release deletes the file.
+//
+// EXAMPLE:
+//
+//	storage.SetReferenceCounter(service.NewAdminFileReferenceUseCase())
+func SetReferenceCounter(counter ReferenceCounter) {
+	referenceCounterMu.Lock()
+	defer referenceCounterMu.Unlock()
+
+	referenceCounter = counter
+}
+
+// References returns the counter set by SetReferenceCounter, nil when disabled.
+func References() ReferenceCounter {
+	referenceCounterMu.RLock()
+	defer referenceCounterMu.RUnlock()
+
+	return referenceCounter
+}
+
+// Retain adds a reference to key when reference counting is enabled.
+func Retain(ctx context.Context, key string) error {
+	counter := References()
+	if counter == nil {
+		return nil
+	}
+
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = counter.Retain(ctx, cleaned)
+	return err
+}
+
+// Release removes a reference to key and deletes the file once no reference is left.
+// Reports whether the file was deleted.
+func Release(ctx context.Context, backend Backend, key string) (bool, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	if counter := References(); counter != nil {
+		remaining, err := counter.Release(ctx, cleaned)
+		if err != nil {
+			return false, err
+		}
+		if remaining > 0 {
+			return false, nil
+		}
+	}
+
+	if err := backend.Delete(ctx, cleaned); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Forget checks that no reference to key is counted before the file is deleted out of Release,
+// e.g. by a sweeper of orphan files. Always true when reference counting is disabled.
+func Forget(ctx context.Context, key string) (bool, error) {
+	counter := References()
+	if counter == nil {
+		return true, nil
+	}
+
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	return counter.Forget(ctx, cleaned)
+}
+
+// MemoryReferenceCounter counts references in process, for tests and single instance deployments.
+type MemoryReferenceCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewMemoryReferenceCounter is a constructor to initialize MemoryReferenceCounter
+func NewMemoryReferenceCounter() *MemoryReferenceCounter {
+	return &MemoryReferenceCounter{counts: map[string]int{}}
+}
+
+func (c *MemoryReferenceCounter) Retain(_ context.Context, key string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts[key]++
+	return c.counts[key], nil
+}
+
+func (c *MemoryReferenceCounter) Release(_ context.Context, key string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts[key] <= 1 {
+		delete(c.counts, key)
+		return 0, nil
+	}
+
+	c.counts[key]--
+	return c.counts[key], nil
+}
+
+func (c *MemoryReferenceCounter) Forget(_ context.Context, key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts[key] > 0 {
+		return false, nil
+	}
+
+	delete(c.counts, key)
+	return true, nil
+}
@@ -0,0 +1,351 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type S3Options struct {
+	// e.g. "https://s3.ap-southeast-1.amazonaws.com" or "http://localhost:9000" for MinIO.
+	Endpoint string
+	// Defaults to "us-east-1".
+	Region       string
+	Bucket       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// Use "<endpoint>/<bucket>/<key>" instead of "<bucket>.<endpoint host>/<key>".
+	PathStyle bool
+	// Base URL of the files, e.g. a CDN. Defaults to the bucket URL.
+	PublicURL string
+	// Defaults to a client with a 30 seconds timeout.
+	HTTPClient *http.Client
+}
+
+// S3Backend stores files in an S3-compatible bucket (AWS S3, MinIO, Cloudflare R2...),
+// signing requests with AWS Signature Version 4.
+type S3Backend struct {
+	options S3Options
+	baseURL *url.URL
+}
+
+// NewS3Backend is a constructor to initialize S3Backend
+func NewS3Backend(opts S3Options) (*S3Backend, error) {
+	if opts.Endpoint == "" || opts.Bucket == "" {
+		return nil, errors.New("storage: S3 endpoint and bucket are required")
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(opts.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, errors.New("storage: invalid S3 endpoint")
+	}
+
+	baseURL := *endpoint
+	if opts.PathStyle {
+		baseURL.Path += "/" + opts.Bucket
+	} else {
+		baseURL.Host = opts.Bucket + "." + endpoint.Host
+	}
+
+	if opts.PublicURL == "" {
+		opts.PublicURL = baseURL.String()
+	}
+
+	return &S3Backend{options: opts, baseURL: &baseURL}, nil
+}
+
+func (b *S3Backend) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) error {
+	// Buffered to sign the payload hash, images are small enough.
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("storage: reading content: %w", err)
+	}
+
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	res, err := b.do(ctx, http.MethodPut, key, body, map[string]string{"Content-Type": contentType})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkS3Response(res)
+}
+
+func (b *S3Backend) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	res, err := b.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkS3Response(res); err != nil {
+		res.Body.Close()
+		return nil, nil, err
+	}
+
+	cleaned, _ := CleanKey(key)
+	return res.Body, objectInfoFromHeader(cleaned, res), nil
+}
+
+func (b *S3Backend) Delete(ctx context.Context, key string) error {
+	// S3 deletes missing keys silently, check first to report ErrNotFound like the other backends.
+	if _, err := b.Stat(ctx, key); err != nil {
+		return err
+	}
+
+	res, err := b.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkS3Response(res)
+}
+
+func (b *S3Backend) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	res, err := b.do(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if err := checkS3Response(res); err != nil {
+		return nil, err
+	}
+
+	cleaned, _ := CleanKey(key)
+	return objectInfoFromHeader(cleaned, res), nil
+}
+
+func (b *S3Backend) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	continuationToken := ""
+
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		target := *b.baseURL
+		if target.Path == "" {
+			target.Path = "/"
+		}
+		// SigV4 encodes spaces as %20.
+		target.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+		res, err := b.send(ctx, http.MethodGet, target, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		var result s3ListResult
+		err = checkS3Response(res)
+		if err == nil {
+			if decodeErr := xml.NewDecoder(res.Body).Decode(&result); decodeErr != nil {
+				err = fmt.Errorf("storage: decoding S3 listing: %w", decodeErr)
+			}
+		}
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, object := range result.Contents {
+			info := ObjectInfo{
+				Key:         object.Key,
+				Size:        object.Size,
+				ContentType: contentTypeByExtension(object.Key),
+				ModTime:     object.LastModified,
+				ETag:        object.ETag,
+			}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+// s3ListResult is the response of ListObjectsV2.
+type s3ListResult struct {
+	Contents []struct {
+		Key          string
+		LastModified time.Time
+		ETag         string
+		Size         int64
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (b *S3Backend) URL(key string) string {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return ""
+	}
+
+	return joinURL(b.options.PublicURL, escapeS3Path(cleaned))
+}
+
+func (b *S3Backend) do(
+	ctx context.Context,
+	method string,
+	key string,
+	body []byte,
+	headers map[string]string,
+) (*http.Response, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	target := *b.baseURL
+	target.Path += "/" + cleaned
+	target.RawPath = b.baseURL.EscapedPath() + "/" + escapeS3Path(cleaned)
+
+	return b.send(ctx, method, target, body, headers)
+}
+
+// send signs and sends a request to target.
+func (b *S3Backend) send(
+	ctx context.Context,
+	method string,
+	target url.URL,
+	body []byte,
+	headers map[string]string,
+) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+	req.ContentLength = int64(len(body))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	b.sign(req, body, time.Now().UTC())
+
+	res, err := b.options.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+
+	return res, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to req.
+func (b *S3Backend) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if b.options.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.options.SessionToken)
+	}
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	if b.options.SessionToken != "" {
+		signedHeaders = append(signedHeaders, "x-amz-security-token")
+		canonicalHeaders += "x-amz-security-token:" + b.options.SessionToken + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + b.options.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+b.options.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, b.options.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+b.options.AccessKey+"/"+scope+
+		", SignedHeaders="+strings.Join(signedHeaders, ";")+
+		", Signature="+signature)
+}
+
+func checkS3Response(res *http.Response) error {
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if res.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("storage: S3 responded %d: %s", res.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+func objectInfoFromHeader(key string, res *http.Response) *ObjectInfo {
+	info := &ObjectInfo{
+		Key:         key,
+		Size:        res.ContentLength,
+		ContentType: res.Header.Get("Content-Type"),
+		ETag:        res.Header.Get("ETag"),
+	}
+	if size, err := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64); err == nil {
+		info.Size = size
+	}
+	if modTime, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+
+	return info
+}
+
+// escapeS3Path URI-encodes every segment of key as required by SigV4.
+func escapeS3Path(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(segment), "+", "%2B")
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
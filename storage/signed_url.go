@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("storage: invalid signature")
+	ErrExpiredSignature = errors.New("storage: signed URL expired")
+)
+
+// URLSigner creates and verifies expiring URLs of stored files, signed with HMAC-SHA256,
+// for private files (receipts, exported reports...) served by middleware.ServeSignedFiles.
+type URLSigner struct {
+	key     []byte
+	baseURL string
+}
+
+// NewURLSigner is a constructor to initialize URLSigner. baseURL is where middleware.ServeSignedFiles
+// is mounted, e.g. "https://api.example.com/files". key should be at least 32 random bytes.
+func NewURLSigner(key []byte, baseURL string) (*URLSigner, error) {
+	if len(key) == 0 {
+		return nil, errors.New("storage: URL signing key is required")
+	}
+
+	return &URLSigner{key: key, baseURL: baseURL}, nil
+}
+
+// SignURL returns the URL of key, valid for ttl.
+//
+// EXAMPLE:
+//
+//	signer, _ := storage.NewURLSigner([]byte(os.Getenv("FILE_URL_KEY")), "https://api.example.com/files")
+//	link, err := signer.SignURL("/"+storeId.Hex()+"/receipts/abc.pdf", 15*time.Minute)
+//	// https://api.example.com/files/<storeId>/receipts/abc.pdf?expires=1700000900&signature=...
+func (s *URLSigner) SignURL(key string, ttl time.Duration) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {s.signature(cleaned, expires)},
+	}
+
+	return joinURL(s.baseURL, escapeS3Path(cleaned)) + "?" + query.Encode(), nil
+}
+
+// Verify checks the expires and signature query parameters of a signed URL of key.
+// Returns ErrInvalidSignature or ErrExpiredSignature.
+func (s *URLSigner) Verify(key string, expires string, signature string, now time.Time) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	expected := s.signature(cleaned, expires)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if now.Unix() > expiresAt {
+		return ErrExpiredSignature
+	}
+
+	return nil
+}
+
+func (s *URLSigner) signature(key string, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(key + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
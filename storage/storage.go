@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("storage: object not found")
+	ErrInvalidKey = errors.New("storage: invalid object key")
+)
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	ETag        string
+}
+
+type PutOptions struct {
+	// Defaults to "application/octet-stream".
+	ContentType string
+}
+
+// Backend stores files by key, a slash separated path such as "<storeId>/product/1700000000.jpg".
+// Implemented by LocalBackend, S3Backend and MemoryBackend.
+type Backend interface {
+	// Put stores the content of r at key, replacing any existing object.
+	Put(ctx context.Context, key string, r io.Reader, opts PutOptions) error
+	// Get returns the content of key, to be closed by the caller. ErrNotFound when missing.
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	// Delete removes key. ErrNotFound when missing.
+	Delete(ctx context.Context, key string) error
+	// Stat describes key without reading it. ErrNotFound when missing.
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// URL returns the public URL of key.
+	URL(key string) string
+}
+
+// Lister is implemented by backends able to enumerate their files (all the backends of this package).
+type Lister interface {
+	// List calls fn for each file whose key starts with prefix, stopping at the first error.
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+}
+
+// CleanKey normalizes a key and rejects keys escaping the storage root.
+// Leading slashes are dropped, so "/product/a.jpg" and "product/a.jpg" are the same key.
+func CleanKey(key string) (string, error) {
+	if strings.ContainsAny(key, "\\\x00") {
+		return "", ErrInvalidKey
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if cleaned == "" || cleaned == "." {
+		return "", ErrInvalidKey
+	}
+
+	return cleaned, nil
+}
+
+var (
+	defaultBackend Backend
+	defaultMu      sync.Mutex
+)
+
+// Default returns the backend used by the image helpers, created from env vars by FromEnv on first use.
+// Panics when the env vars are invalid, like a missing MONGO_URI would fail at startup.
+func Default() Backend {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultBackend == nil {
+		backend, err := FromEnv()
+		if err != nil {
+			panic(err)
+		}
+		defaultBackend = backend
+	}
+
+	return defaultBackend
+}
+
+// SetDefault replaces the backend used by the image helpers, e.g. a MemoryBackend in tests.
+func SetDefault(backend Backend) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	defaultBackend = backend
+}
+
+// FromEnv creates a backend from env vars, so deployments can switch storage without code changes.
+//
+//   - STORAGE_DRIVER: "local" (default) or "s3"
+//   - STORAGE_PUBLIC_URL: base URL of the files, e.g. "https://files.example.com"
+//   - STORAGE_ROOT: root directory of "local", defaults to "../acts-files"
+//   - S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY,
+//     S3_SESSION_TOKEN and S3_PATH_STYLE ("true" for MinIO and most S3-compatible services): "s3"
+func FromEnv() (Backend, error) {
+	publicURL := os.Getenv("STORAGE_PUBLIC_URL")
+
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		root := os.Getenv("STORAGE_ROOT")
+		if root == "" {
+			root = "../acts-files"
+		}
+		return NewLocalBackend(root, publicURL)
+	case "s3":
+		return NewS3Backend(S3Options{
+			Endpoint:     os.Getenv("S3_ENDPOINT"),
+			Region:       os.Getenv("S3_REGION"),
+			Bucket:       os.Getenv("S3_BUCKET"),
+			AccessKey:    os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("S3_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("S3_SESSION_TOKEN"),
+			PathStyle:    os.Getenv("S3_PATH_STYLE") == "true",
+			PublicURL:    publicURL,
+		})
+	default:
+		return nil, errors.New("storage: unknown STORAGE_DRIVER " + driver)
+	}
+}
+
+// joinURL joins a base URL and a key, "/<key>" without base URL.
+func joinURL(baseURL string, key string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + key
+}
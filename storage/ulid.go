@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+)
+
+// Crockford's base32, as used by ULIDs.
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a ULID: 26 characters, lexicographically sortable by creation time
+// (milliseconds) with 80 random bits, so names never collide.
+func NewULID(now time.Time) string {
+	var id [16]byte
+	ms := uint64(now.UnixMilli())
+	binary.BigEndian.PutUint16(id[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	_, _ = rand.Read(id[6:])
+
+	// 128 bits as 26 base32 characters, the first one holding the top 3 bits.
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = ulidAlphabet[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(out[:])
+}
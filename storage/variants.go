@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// ResizeMode chooses how an image is brought to the size of a Variant. All modes preserve the aspect ratio.
+type ResizeMode string
+
+const (
+	// Scale down to fit inside Width x Height, never upscaled. The default.
+	ResizeFit ResizeMode = "fit"
+	// Scale to cover Width x Height and crop the overflow around the center, for exact sizes (avatars, logos).
+	ResizeFill ResizeMode = "fill"
+	// Cut Width x Height out of the center, without scaling.
+	ResizeCrop ResizeMode = "crop"
+)
+
+// Variant is a named rendition of an uploaded image.
+type Variant struct {
+	// Name of the variant, key of the paths returned by SaveVariants.
+	Name string
+	// Maximum size in pixels. With ResizeFit, 0 leaves that side unconstrained.
+	Width  int
+	Height int
+	// Defaults to ResizeFit.
+	Mode ResizeMode
+	// Overrides SaveImageOptions.Format for this variant when set.
+	Format Format
+	// Overrides SaveImageOptions.Quality for this variant when set, 1 to 100.
+	Quality int
+}
+
+// Default JPEG quality of saved images.
+const DefaultJPEGQuality = 85
+
+// Presets matching Store.LogoSmall/Medium/Big and User.ProfilePictureSmall/Medium/Big.
+var (
+	VariantSmall  = Variant{Name: "small", Width: 150, Height: 150, Mode: ResizeFill, Quality: 80}
+	VariantMedium = Variant{Name: "medium", Width: 480, Height: 480, Mode: ResizeFit, Quality: 85}
+	VariantBig    = Variant{Name: "big", Width: 1280, Height: 1280, Mode: ResizeFit, Quality: 90}
+
+	DefaultVariants = []Variant{VariantSmall, VariantMedium, VariantBig}
+)
+
+// Resize renders img at the size of variant.
+func (v Variant) Resize(img image.Image) image.Image {
+	bounds := img.Bounds()
+
+	switch v.Mode {
+	case ResizeFill:
+		if v.Width <= 0 || v.Height <= 0 {
+			return v.fit(img, bounds)
+		}
+		return imaging.Fill(img, v.Width, v.Height, imaging.Center, imaging.Lanczos)
+	case ResizeCrop:
+		width, height := v.Width, v.Height
+		if width <= 0 || width > bounds.Dx() {
+			width = bounds.Dx()
+		}
+		if height <= 0 || height > bounds.Dy() {
+			height = bounds.Dy()
+		}
+		return imaging.CropCenter(img, width, height)
+	default:
+		return v.fit(img, bounds)
+	}
+}
+
+func (v Variant) fit(img image.Image, bounds image.Rectangle) image.Image {
+	width, height := v.Width, v.Height
+	if (width <= 0 || bounds.Dx() <= width) && (height <= 0 || bounds.Dy() <= height) {
+		return img
+	}
+
+	switch {
+	case width <= 0:
+		return imaging.Resize(img, 0, height, imaging.Lanczos)
+	case height <= 0:
+		return imaging.Resize(img, width, 0, imaging.Lanczos)
+	default:
+		return imaging.Fit(img, width, height, imaging.Lanczos)
+	}
+}
+
+// SaveVariants renders img as each variant and saves them with SaveImage in dir.
+// Returns the key of each variant by name. Variants saved before a failure are released.
+//
+// EXAMPLE:
+//
+//	keys, err := storage.SaveVariants(ctx, storage.Default(), storeId.Hex()+"/logo", img, storage.DefaultVariants)
+//	store.LogoSmall = functions.MakePointer("/" + keys["small"])
+func SaveVariants(
+	ctx context.Context,
+	backend Backend,
+	dir string,
+	img image.Image,
+	variants []Variant,
+	opts ...SaveImageOptions,
+) (map[string]string, error) {
+	keys := make(map[string]string, len(variants))
+
+	for _, variant := range variants {
+		variantOpts := SaveImageOptions{}
+		if len(opts) > 0 {
+			variantOpts = opts[0]
+		}
+		if variant.Format != "" {
+			variantOpts.Format = variant.Format
+		}
+		if variant.Quality > 0 {
+			variantOpts.Quality = variant.Quality
+		}
+
+		key, err := SaveImage(ctx, backend, dir, variant.Resize(img), variantOpts)
+		if err != nil {
+			for _, saved := range keys {
+				_, _ = Release(ctx, backend, saved)
+			}
+			return nil, fmt.Errorf("error saving %s variant: %w", variant.Name, err)
+		}
+
+		keys[variant.Name] = key
+	}
+
+	return keys, nil
+}
@@ -1,11 +1,15 @@
 package validator
 
 import (
+	"errors"
+	"net/url"
+
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	internalvalidator "github.com/kittipat1413/go-common/framework/validator"
 	"github.com/susatyo441/go-ta-utils/entity"
 	"github.com/susatyo441/go-ta-utils/parser"
+	"github.com/susatyo441/go-ta-utils/pipeline"
 	customvalidator "github.com/susatyo441/go-ta-utils/validator/custom_validator"
 )
 
@@ -65,14 +69,19 @@ func ParseAndValidateBody[T any](ctx *fiber.Ctx) (*T, *entity.HttpError) {
 // Compacted common validation to reduce repeated code.
 // Intended to be used in controller
 func ParseAndValidateQuery[T any](ctx *fiber.Ctx) (*T, *entity.HttpError) {
-	// Parse the query
-	rawQuery := ctx.Queries()
+	// Parse the query, keeping repeated keys (?ids=a&ids=b)
+	rawQuery := queryValues(ctx)
 
-	// Transform query using "transform" tag
+	// Transform query using "json", "transform" and "default" tags
 	// LINK: acts-utils/parser/query_parser.go
-	parsedQuery, err := parser.ParseQuery[T](rawQuery)
+	// Strict mode reports every invalid field at once (e.g. ?limit=abc)
+	parsedQuery, err := parser.ParseQueryValues[T](rawQuery, parser.ParseQueryOptions{Strict: true})
 
 	// Return if there is an error when parsing the query
+	var httpErr *entity.HttpError
+	if errors.As(err, &httpErr) {
+		return nil, httpErr
+	}
 	if err != nil {
 		return nil, entity.BadRequest(err.Error())
 	}
@@ -87,6 +96,34 @@ func ParseAndValidateQuery[T any](ctx *fiber.Ctx) (*T, *entity.HttpError) {
 	return parsedQuery, nil
 }
 
+// Parse the filter DSL (?filter[price][gte]=1000&sort=-createdAt) against the allowlist declared on T.
+// Intended to be used in controller
+//
+// LINK: acts-utils/parser/filter_parser.go
+func ParseFilter[T any](ctx *fiber.Ctx) (*pipeline.Filter, *entity.HttpError) {
+	filter, err := parser.ParseFilter[T](queryValues(ctx))
+
+	var httpErr *entity.HttpError
+	if errors.As(err, &httpErr) {
+		return nil, httpErr
+	}
+	if err != nil {
+		return nil, entity.BadRequest(err.Error())
+	}
+
+	return filter, nil
+}
+
+// queryValues returns the raw query, keeping repeated keys.
+func queryValues(ctx *fiber.Ctx) url.Values {
+	values := url.Values{}
+	ctx.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		values.Add(string(key), string(value))
+	})
+
+	return values
+}
+
 func ValidateStruct(obj any) error {
 	validate := validator.New(validator.WithRequiredStructEnabled())
 